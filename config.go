@@ -6,15 +6,18 @@ import (
 )
 
 type Config struct {
-	Maxprocs        int                `toml:"maxprocs"`
-	Dbinfo          map[string]*dbinfo `toml:"dbinfo"`
-	ManagerPort     string             `toml:"managerport"`
-	Port            string             `toml:"port"`
-	Loglevel        uint8              `toml:"loglevel"`
-	SchedulePidFile string             `toml:"schedule_pid_file"`
-	WorkerPidFile   string             `toml:"worker_pid_file"`
-	CpuProfName     string             `toml:"cpuprof"`
-	MemProfName     string             `toml:"memprof"`
+	Maxprocs            int                `toml:"maxprocs"`
+	Dbinfo              map[string]*dbinfo `toml:"dbinfo"`
+	ManagerPort         string             `toml:"managerport"`
+	Port                string             `toml:"port"`
+	Loglevel            uint8              `toml:"loglevel"`
+	SchedulePidFile     string             `toml:"schedule_pid_file"`
+	WorkerPidFile       string             `toml:"worker_pid_file"`
+	CpuProfName         string             `toml:"cpuprof"`
+	MemProfName         string             `toml:"memprof"`
+	AlertUrl            string             `toml:"alert_url"`
+	MaxRunning          int                `toml:"max_running"`
+	MaxRunningPerWorker int                `toml:"max_running_per_worker"`
 }
 
 type dbinfo struct {
@@ -1,12 +1,17 @@
 package manager
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-martini/martini"
 	"github.com/martini-contrib/binding"
 	"github.com/martini-contrib/render"
 	"github.com/martini-contrib/web"
 	"gitlab.51idc.com/hds/scheduling/schedule"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
@@ -17,6 +22,10 @@ var (
 	g *schedule.GlobalConfigStruct
 )
 
+const (
+	maxConfSize = 1 << 20 //webhook请求体的最大长度
+)
+
 //初始化并启动web服务
 func StartManager(sl *schedule.ScheduleManager) { // {{{
 	g = sl.Global
@@ -40,7 +49,7 @@ func StartManager(sl *schedule.ScheduleManager) { // {{{
 	g.L.Println("Web manager is running in ", g.ManagerPort)
 	err := http.ListenAndServe(g.ManagerPort, m)
 	if err != nil {
-		log.Fatal("Fail to start server: %v", err)
+		log.Fatalf("Fail to start server: %v", err)
 	}
 } // }}}
 
@@ -54,6 +63,7 @@ func controller(m *martini.ClassicMartini) { // {{{
 		//Task部分
 		r.Get("", GetScheduleById)
 		r.Get("/:id", GetTask)
+		r.Get("/:id/logs", GetTaskLogs)
 		r.Post("", binding.Bind(schedule.Task{}), AddTask)
 		r.Put("/:id", binding.Bind(schedule.Task{}), UpdateTask)
 		r.Delete("/:id", DeleteTask)
@@ -65,6 +75,19 @@ func controller(m *martini.ClassicMartini) { // {{{
 		r.Post("/dotasks/:id", DoTask)
 	})
 
+	m.Group("/schedules", func(r martini.Router) {
+		//Schedule部分
+		r.Get("", GetSchedules)
+		r.Get("/:id", GetScheduleById)
+
+		//回填执行
+		r.Post("/:sid/backfill", binding.Bind(BackfillParam{}), Backfill)
+
+		//webhook触发执行
+		r.Post("/:sid/trigger", Webhook)
+		r.Put("/:sid/token", ResetToken)
+	})
+
 } // }}}
 
 //返回当前的调度列表
@@ -121,7 +144,9 @@ func UpdateSchedule(params martini.Params, r render.Render, Ss *schedule.Schedul
 	}
 	fmt.Println(scd)
 	if s := Ss.GetScheduleById(int64(scd.Id)); s != nil {
-		s.Name, s.Desc, s.Cyc = scd.Name, scd.Desc, scd.Cyc
+		s.Name, s.Desc, s.Cyc, s.Count = scd.Name, scd.Desc, scd.Cyc, scd.Count
+		s.StartList = scd.StartList
+		s.SlaStart, s.SlaFinish, s.SlaDuration = scd.SlaStart, scd.SlaFinish, scd.SlaDuration
 		s.ModifyTime, s.ModifyUserId = time.Now(), scd.ModifyUserId
 		if err := s.UpdateSchedule(); err != nil {
 			e := fmt.Sprintf("[UpdateSchedule] update schedule error %s.", err.Error())
@@ -252,6 +277,34 @@ func GetTask(params martini.Params, r render.Render, Ss *schedule.ScheduleManage
 	}
 }
 
+//GetTaskLogs返回任务最近的执行记录，包含每次执行的失败类型及退出码。
+//参数limit指定返回的记录数，默认20，最大1000。
+func GetTaskLogs(params martini.Params, req *http.Request, r render.Render) { // {{{
+	id, _ := strconv.Atoi(params["id"])
+	if id == 0 {
+		e := fmt.Sprintf("[GetTaskLogs] id is required")
+		g.L.Warningln(e)
+		r.JSON(500, e)
+		return
+	}
+
+	limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	} else if limit > 1000 {
+		limit = 1000
+	}
+
+	logs, err := schedule.GetTaskLogs(int64(id), limit)
+	if err != nil {
+		e := fmt.Sprintf("[GetTaskLogs] %s", err.Error())
+		g.L.Warningln(e)
+		r.JSON(500, e)
+		return
+	}
+	r.JSON(200, logs)
+} // }}}
+
 //addTask获取客户端发送的Task信息，调用Task的AddTask方法持久化。
 //成功后根据其中的JobId找到对应Job将其添加
 //成功返回添加好的Job信息
@@ -267,6 +320,17 @@ func AddTask(params martini.Params, r render.Render, Ss *schedule.ScheduleManage
 	}
 
 	t := &task
+	//检查依赖的任务
+	if s := Ss.GetScheduleById(int64(ssid)); s != nil {
+		for _, relid := range t.RelTasksId {
+			if err := s.CheckRelTask(0, relid); err != nil {
+				e := fmt.Sprintf("[AddTask] reltask error %s.", err.Error())
+				g.L.Warningln(e)
+				r.JSON(500, e)
+				return
+			}
+		}
+	}
 	//t.TaskType = 1
 	t.CreateUserId = 1
 	t.ModifyUserId = 1
@@ -309,6 +373,63 @@ func DoTask(params martini.Params, r render.Render, Ss *schedule.ScheduleManager
 	}
 }
 
+//回填执行的参数
+type BackfillParam struct { // {{{
+	Start    string  `form:"start" json:"start"`       //开始时间，格式 2006-01-02 或 2006-01-02 15:04:05
+	End      string  `form:"end" json:"end"`           //结束时间，格式同开始时间
+	TaskIds  []int64 `form:"taskIds" json:"taskIds"`   //需要回填的任务，为空时回填调度中的全部任务
+	Parallel int     `form:"parallel" json:"parallel"` //同时执行的周期数量
+} // }}}
+
+//Backfill按调度周期回填执行start至end之间的每个周期，回填在后台执行。
+//成功返回需要回填的周期列表
+func Backfill(params martini.Params, r render.Render, Ss *schedule.ScheduleManager, bp BackfillParam) { // {{{
+	sid, _ := strconv.Atoi(params["sid"])
+
+	s := Ss.GetScheduleById(int64(sid))
+	if s == nil {
+		e := fmt.Sprintf("[Backfill] Not Found Schedule[%d].", sid)
+		g.L.Warningln(e)
+		r.JSON(500, e)
+		return
+	}
+
+	start, err := schedule.ParseTime(bp.Start)
+	if err != nil {
+		e := fmt.Sprintf("[Backfill] start time [%s] error %s.", bp.Start, err.Error())
+		g.L.Warningln(e)
+		r.JSON(500, e)
+		return
+	}
+	end, err := schedule.ParseTime(bp.End)
+	if err != nil {
+		e := fmt.Sprintf("[Backfill] end time [%s] error %s.", bp.End, err.Error())
+		g.L.Warningln(e)
+		r.JSON(500, e)
+		return
+	}
+
+	for _, id := range bp.TaskIds {
+		if s.GetTaskById(id) == nil {
+			e := fmt.Sprintf("[Backfill] Not Found Task[%d] in Schedule[%d].", id, sid)
+			g.L.Warningln(e)
+			r.JSON(500, e)
+			return
+		}
+	}
+
+	periods, err := s.BackfillPeriods(start, end)
+	if err != nil {
+		e := fmt.Sprintf("[Backfill] %s", err.Error())
+		g.L.Warningln(e)
+		r.JSON(500, e)
+		return
+	}
+
+	go s.Backfill(bp.TaskIds, periods, bp.Parallel)
+	r.JSON(200, periods)
+} // }}}
+
 //deleteTask从调度结构中删除指定的Task，并持久化。
 func DeleteTask(params martini.Params, r render.Render, Ss *schedule.ScheduleManager) { // {{{
 	sid, _ := strconv.Atoi(params["sid"])
@@ -324,7 +445,7 @@ func DeleteTask(params martini.Params, r render.Render, Ss *schedule.ScheduleMan
 	if s := Ss.GetScheduleById(int64(sid)); s != nil {
 		t := s.GetTaskById(int64(id))
 		if err := t.Delete(); err != nil {
-			e := fmt.Sprintf("\n[s.DeleteTask] schedule [%d] Delete error %s.", err.Error())
+			e := fmt.Sprintf("\n[s.DeleteTask] schedule [%d] Delete error %s.", sid, err.Error())
 			r.JSON(500, e)
 			return
 		}
@@ -358,9 +479,15 @@ func UpdateTask(params martini.Params, r render.Render, Ss *schedule.ScheduleMan
 	if s := Ss.GetScheduleById(int64(ssid)); s != nil {
 		t := s.GetTaskById(int64(id))
 		t.Name, t.Desc, t.Address = task.Name, task.Desc, task.Address
-		t.TaskType, t.TaskCyc, t.StartSecond = task.TaskType, task.TaskCyc, task.StartSecond
+		t.TaskType, t.TaskCyc, t.StartSecond, t.StartList = task.TaskType, task.TaskCyc, task.StartSecond, task.StartList
 		t.Cmd, t.TimeOut = task.Cmd, task.TimeOut
 		t.Cronstr, t.Retry, t.Concurrent = task.Cronstr, task.Retry, task.Concurrent
+		t.Misfire, t.Overlap, t.RelWaitTime = task.Misfire, task.Overlap, task.RelWaitTime
+		t.TriggerRule, t.JoinWindow, t.ExecMode = task.TriggerRule, task.JoinWindow, task.ExecMode
+		t.Executor = task.Executor
+		t.Retry, t.RetryDelay, t.Backoff = task.Retry, task.RetryDelay, task.Backoff
+		t.MaxDelay, t.RetryOn = task.MaxDelay, task.RetryOn
+		t.SlaStart, t.SlaFinish, t.SlaDuration = task.SlaStart, task.SlaFinish, task.SlaDuration
 		t.Attr, t.ModifyUserId, t.ModifyTime = task.Attr, task.ModifyUserId, NowTimePtr()
 		if err := t.UpdateTask(); err != nil {
 			e := fmt.Sprintf("\n[UpdateTask] UpdateTask error %s.", err.Error())
@@ -439,27 +566,19 @@ func AddRelTask(params martini.Params, ctx *web.Context, r render.Render, Ss *sc
 	}
 
 	if s := Ss.GetScheduleById(int64(sid)); s != nil {
-		t := s.GetTaskById(int64(id))
-		rt := s.GetTaskById(int64(relid))
-
-		if t == nil || rt == nil {
-			e := fmt.Sprintf("[AddRelTask] task or reltask is required")
-			g.L.Warningln(e)
-			r.JSON(500, e)
-			return
-		}
-
-		err := t.AddRelTask(rt)
+		//检查依赖关系后增加依赖并刷新任务
+		t, err := s.AddRelTask(int64(id), int64(relid))
 		if err != nil {
 			e := fmt.Sprintf("[AddRelTask] add task is error %s.", err.Error())
 			g.L.Warningln(e)
 			r.JSON(500, e)
 			return
 		}
-		//	if s := Ss.GetScheduleById(int64(sid)); s != nil {
-		s.UpdateTask(t)
-		//	}
 		r.JSON(200, t)
+	} else {
+		e := fmt.Sprintf("[AddRelTask] Not Found Schedule[%d].", sid)
+		g.L.Warningln(e)
+		r.JSON(500, e)
 	}
 
 } // }}}
@@ -529,3 +648,92 @@ func NowTimePtr() *time.Time {
 	t := time.Now()
 	return &t
 }
+
+//Webhook由外部系统调用，启动指定调度的执行批次。
+//令牌在请求头X-Scd-Token或参数token中传入，参数task指定只执行其中一个任务，
+//请求体为json对象，作为批次的参数供任务使用。成功返回批次ID。
+func Webhook(params martini.Params, req *http.Request, r render.Render, Ss *schedule.ScheduleManager) { // {{{
+	sid, _ := strconv.Atoi(params["sid"])
+
+	s := Ss.GetScheduleById(int64(sid))
+	if s == nil {
+		e := fmt.Sprintf("[Webhook] Not Found Schedule[%d].", sid)
+		g.L.Warningln(e)
+		r.JSON(404, e)
+		return
+	}
+
+	token := req.Header.Get("X-Scd-Token")
+	if token == "" {
+		token = req.URL.Query().Get("token")
+	}
+
+	var taskId int64
+	if ts := req.URL.Query().Get("task"); ts != "" {
+		id, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			e := fmt.Sprintf("[Webhook] task id [%s] error %s.", ts, err.Error())
+			g.L.Warningln(e)
+			r.JSON(500, e)
+			return
+		}
+		taskId = id
+	}
+
+	var conf map[string]interface{}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxConfSize))
+	if err != nil {
+		e := fmt.Sprintf("[Webhook] read body error %s.", err.Error())
+		g.L.Warningln(e)
+		r.JSON(500, e)
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err = json.Unmarshal(body, &conf); err != nil {
+			e := fmt.Sprintf("[Webhook] body is not a json object. %s", err.Error())
+			g.L.Warningln(e)
+			r.JSON(500, e)
+			return
+		}
+	}
+
+	es, err := s.Webhook(token, taskId, conf)
+	if err != nil {
+		e := fmt.Sprintf("[Webhook] %s", err.Error())
+		g.L.Warningln(e)
+		//只有令牌不一致时拒绝访问，其它为任务不存在或启动批次失败
+		code := 500
+		if errors.Is(err, schedule.ErrInvalidToken) {
+			code = 403
+		} else if errors.Is(err, schedule.ErrTaskNotFound) {
+			code = 404
+		}
+		r.JSON(code, e)
+		return
+	}
+
+	go es.Run()
+	r.JSON(200, es.BatchId())
+} // }}}
+
+//ResetToken为调度生成新的webhook令牌，返回新的令牌
+func ResetToken(params martini.Params, r render.Render, Ss *schedule.ScheduleManager) { // {{{
+	sid, _ := strconv.Atoi(params["sid"])
+
+	s := Ss.GetScheduleById(int64(sid))
+	if s == nil {
+		e := fmt.Sprintf("[ResetToken] Not Found Schedule[%d].", sid)
+		g.L.Warningln(e)
+		r.JSON(500, e)
+		return
+	}
+
+	token, err := s.ResetToken()
+	if err != nil {
+		e := fmt.Sprintf("[ResetToken] %s", err.Error())
+		g.L.Warningln(e)
+		r.JSON(500, e)
+		return
+	}
+	r.JSON(200, token)
+} // }}}
@@ -2,7 +2,8 @@
 SQLyog Community v12.09 (64 bit)
 MySQL - 5.7.17-0ubuntu0.16.04.1 : Database - schedule_dev
 *********************************************************************
-*/
+*/
+
 
 /*!40101 SET NAMES utf8 */;
 
@@ -16,6 +17,41 @@ CREATE DATABASE /*!32312 IF NOT EXISTS*/`schedule_dev` /*!40100 DEFAULT CHARACTE
 
 USE `schedule_dev`;
 
+/*Table structure for table `scd_file_trigger` */
+
+DROP TABLE IF EXISTS `scd_file_trigger`;
+
+CREATE TABLE `scd_file_trigger` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT '触发器id',
+  `scd_id` bigint(20) NOT NULL COMMENT '调度id',
+  `task_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '任务id，0时触发调度中全部未禁用的任务',
+  `watch_dir` varchar(512) NOT NULL COMMENT '监听的本地目录',
+  `pattern` varchar(256) NOT NULL DEFAULT '*' COMMENT '文件名匹配的glob表达式',
+  `debounce` int(11) NOT NULL DEFAULT '5' COMMENT '文件最后一次变化后等待的时间，单位 秒',
+  `disabled` tinyint(4) NOT NULL DEFAULT '0',
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='文件触发器：\n           调度部分，监听调度模块所在主机的本地目录，匹配的文件写入完成后触发执行。';
+
+/*Data for the table `scd_file_trigger` */
+
+/*Table structure for table `scd_file_processed` */
+
+DROP TABLE IF EXISTS `scd_file_processed`;
+
+CREATE TABLE `scd_file_processed` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `trigger_id` bigint(20) NOT NULL COMMENT '触发器id',
+  `file_path` varchar(768) NOT NULL COMMENT '文件路径',
+  `file_size` bigint(20) NOT NULL DEFAULT '0' COMMENT '触发时的文件大小',
+  `file_mtime` datetime DEFAULT NULL COMMENT '触发时的文件修改时间，同一路径的文件大小或修改时间变化后再次触发',
+  `batch_id` varchar(128) NOT NULL COMMENT '文件触发的批次ID',
+  `create_time` datetime NOT NULL COMMENT '触发时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `trigger_file` (`trigger_id`,`file_path`,`file_size`,`file_mtime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='已处理文件表：\n           日志部分，记录文件触发器已处理的文件，每个文件只触发一次。';
+
+/*Data for the table `scd_file_processed` */
+
 /*Table structure for table `scd_job` */
 
 DROP TABLE IF EXISTS `scd_job`;
@@ -27,8 +63,8 @@ CREATE TABLE `scd_job` (
   `job_desc` varchar(500) DEFAULT NULL COMMENT '作业说明',
   `prev_job_id` bigint(20) NOT NULL COMMENT '上级作业id',
   `next_job_id` bigint(20) NOT NULL COMMENT '下级作业id',
-  `exec_type` tinyint(4) NOT NULL DEFAULT '0',
-  `disabled` tinyint(4) NOT NULL DEFAULT '0',
+  `exec_type` tinyint(4) NOT NULL DEFAULT '0' COMMENT '作业中有任务失败时的处理方式 0.阻塞下级作业 1.继续执行下级作业',
+  `disabled` tinyint(4) NOT NULL DEFAULT '0' COMMENT '禁用的作业不执行，执行日志中记录为忽略',
   `create_user_id` bigint(20) DEFAULT NULL COMMENT '创建人',
   `create_time` datetime DEFAULT NULL COMMENT '创建时间',
   `modify_user_id` bigint(20) DEFAULT NULL COMMENT '修改人',
@@ -50,9 +86,9 @@ CREATE TABLE `scd_job_log` (
   `batch_id` varchar(128) NOT NULL COMMENT '批次ID，规则scheduleId + 周期开始时间(不含周期内启动时间)',
   `start_time` datetime DEFAULT NULL COMMENT '开始时间',
   `end_time` datetime DEFAULT NULL COMMENT '结束时间',
-  `state` varchar(1) DEFAULT NULL COMMENT '状态 0.不满足条件未执行 1. 执行中 2. 暂停 3. 完成 4.意外中止',
+  `state` varchar(1) DEFAULT NULL COMMENT '状态 0.不满足条件未执行 1. 执行中 2. 暂停 3. 完成 4.意外中止 5.忽略',
   `result` decimal(10,2) DEFAULT NULL COMMENT '结果,作业中执行成功任务的百分比',
-  `batch_type` varchar(1) NOT NULL COMMENT '执行类型 1. 自动定时调度 2.手动人工调度 3.修复执行',
+  `batch_type` varchar(1) NOT NULL COMMENT '执行类型 1. 自动定时调度 2.手动人工调度 3.修复执行 4.错过补执行 5.回填执行 6.依赖汇合执行 7.webhook触发执行 8.文件到达触发执行',
   PRIMARY KEY (`log_id`),
   KEY `job_id` (`job_id`,`batch_job_id`,`batch_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='作业执行信息表：\n           日志部分，记录作业执行情况。';
@@ -67,10 +103,16 @@ CREATE TABLE `scd_schedule` (
   `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT '调度id',
   `scd_name` varchar(256) NOT NULL COMMENT '调度名称',
   `scd_num` int(11) NOT NULL COMMENT '调度次数 0.不限次数 ',
+  `scd_run_num` int(11) NOT NULL DEFAULT '0' COMMENT '已执行次数，自动定时调度完成的批次数量',
   `scd_cyc` varchar(2) NOT NULL COMMENT '调度周期 ss 秒 mi 分钟 h 小时 d 日 m 月 w 周 q 季度 y 年',
+  `scd_start_list` varchar(256) NOT NULL DEFAULT '' COMMENT '周期内的启动时间，逗号分隔，格式 [月份偏移:]秒数偏移，为空时在周期开始时启动',
   `scd_timeout` bigint(20) DEFAULT NULL COMMENT '最大执行时间，单位 秒',
   `scd_job_id` bigint(20) DEFAULT NULL COMMENT '作业id',
+  `scd_sla_start` varchar(16) NOT NULL DEFAULT '' COMMENT '最晚开始时间，格式为秒数时表示相对于周期开始时间的偏移，格式为hh:mi[:ss]时表示周期开始当天的时刻，为空不限制',
+  `scd_sla_finish` varchar(16) NOT NULL DEFAULT '' COMMENT '最晚完成时间，格式为秒数时表示相对于周期开始时间的偏移，格式为hh:mi[:ss]时表示周期开始当天的时刻，为空不限制',
+  `scd_sla_duration` bigint(20) NOT NULL DEFAULT '0' COMMENT '最长执行时间，单位 秒，0不限制',
   `scd_desc` varchar(500) DEFAULT NULL COMMENT '调度说明',
+  `scd_token` varchar(64) NOT NULL DEFAULT '' COMMENT 'webhook触发调度的令牌，为空时不能通过webhook触发',
   `create_user_id` varchar(30) NOT NULL COMMENT '创建人',
   `create_time` date NOT NULL COMMENT '创建时间',
   `modify_user_id` varchar(30) DEFAULT NULL COMMENT '修改人',
@@ -88,17 +130,38 @@ CREATE TABLE `scd_schedule_log` (
   `log_id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
   `scd_id` bigint(20) NOT NULL COMMENT '调度id',
   `batch_id` varchar(128) NOT NULL COMMENT '批次ID，规则scheduleId + 周期开始时间(不含周期内启动时间)',
+  `run_time` datetime DEFAULT NULL COMMENT '批次对应的启动时间，跨调度依赖按该时间匹配周期',
   `start_time` datetime DEFAULT NULL COMMENT '开始时间',
   `end_time` datetime DEFAULT NULL COMMENT '结束时间',
   `state` varchar(1) DEFAULT NULL COMMENT '状态 0.不满足条件未执行 1. 执行中 2. 暂停 3. 完成 4.失败',
   `result` decimal(10,2) DEFAULT NULL COMMENT '结果,调度中执行成功任务的百分比',
-  `batch_type` varchar(1) DEFAULT NULL COMMENT '执行类型 1. 自动定时调度 2.手动人工调度 3.修复执行',
+  `batch_type` varchar(1) DEFAULT NULL COMMENT '执行类型 1. 自动定时调度 2.手动人工调度 3.修复执行 4.错过补执行 5.回填执行 6.依赖汇合执行 7.webhook触发执行 8.文件到达触发执行',
   PRIMARY KEY (`log_id`),
   KEY `scd_batch_id` (`scd_id`,`batch_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='用户调度权限表：\n           日志部分，记录调度执行情况。';
 
 /*Data for the table `scd_schedule_log` */
 
+/*Table structure for table `scd_sla_miss` */
+
+DROP TABLE IF EXISTS `scd_sla_miss`;
+
+CREATE TABLE `scd_sla_miss` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `scd_id` bigint(20) NOT NULL COMMENT '调度id',
+  `task_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '任务id，调度的SLA为0',
+  `batch_id` varchar(128) NOT NULL COMMENT '批次ID，周期内没有批次时为空',
+  `batch_task_id` varchar(128) NOT NULL DEFAULT '' COMMENT '任务批次id，调度的SLA为空',
+  `miss_type` varchar(16) NOT NULL COMMENT '类型 start.未按时开始 finish.未按时完成 duration.超过最长执行时间',
+  `deadline` datetime NOT NULL COMMENT '应开始或完成的时间',
+  `detect_time` datetime NOT NULL COMMENT '发现时间',
+  PRIMARY KEY (`id`),
+  KEY `batch_id` (`batch_id`),
+  KEY `scd_deadline` (`scd_id`,`deadline`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='SLA未达成记录：\n           日志部分，记录调度及任务未按时开始、完成或超过最长执行时间的情况。';
+
+/*Data for the table `scd_sla_miss` */
+
 /*Table structure for table `scd_task` */
 
 DROP TABLE IF EXISTS `scd_task`;
@@ -112,11 +175,26 @@ CREATE TABLE `scd_task` (
   `task_cyc` varchar(2) NOT NULL DEFAULT '' COMMENT '调度周期 ss 秒 mi 分钟 h 小时 d 日 m 月 w 周 q 季度 y 年',
   `cronstr` varchar(1024) NOT NULL COMMENT 'crontab格式字符串 * * * * * *',
   `retry` int(11) NOT NULL DEFAULT '0' COMMENT '重试次数',
-  `concurrent` int(11) NOT NULL DEFAULT '1',
+  `retry_delay` bigint(20) NOT NULL DEFAULT '0' COMMENT '首次重试前等待的时间，单位 秒',
+  `retry_backoff` decimal(6,2) NOT NULL DEFAULT '1.00' COMMENT '每次重试后等待时间的倍数',
+  `retry_max_delay` bigint(20) NOT NULL DEFAULT '0' COMMENT '重试前等待的最长时间，单位 秒，0不限制',
+  `retry_on` tinyint(4) NOT NULL DEFAULT '0' COMMENT '需要重试的失败 0.全部失败 1.只重试执行模块无法连接及RPC调用错误 2.只重试超时、命令返回非0、被信号结束等命令执行失败',
+  `concurrent` int(11) NOT NULL DEFAULT '0' COMMENT '同时执行的最大实例数量 0.不限制',
+  `misfire` tinyint(4) NOT NULL DEFAULT '0' COMMENT '错过启动时间的处理方式 0.跳过 1.补执行一次 2.补执行全部错过的周期',
+  `overlap` tinyint(4) NOT NULL DEFAULT '1' COMMENT '达到最大实例数量时的处理方式 0.跳过本次执行 1.排队等待 2.中止之前的执行',
+  `rel_wait_time` bigint(20) NOT NULL DEFAULT '21600' COMMENT '等待其它调度中依赖任务的最长时间，单位 秒，0使用默认的6小时',
+  `trigger_rule` tinyint(4) NOT NULL DEFAULT '0' COMMENT '依赖任务结束后的触发规则 0.全部成功 1.全部结束 2.任一失败 3.任一成功',
+  `join_window` bigint(20) NOT NULL DEFAULT '0' COMMENT '依赖任务的汇合时间窗口，单位 秒，大于0时依赖的任务全部在窗口内完成后启动，0跟随第一个依赖的任务启动',
+  `executor` varchar(16) NOT NULL DEFAULT '' COMMENT '执行器 空或shell.执行shell命令 http.发送http请求，命令为请求的URL，请求信息见属性http_method等 sql.在属性sql_conn指定的数据库链接上执行sql，属性sql_assert设置断言 schedule.手动启动命令中指定id的调度，属性schedule_wait为1时等待其完成并以其结果作为任务结果',
+  `sla_start` varchar(16) NOT NULL DEFAULT '' COMMENT '最晚开始时间，格式为秒数时表示相对于周期开始时间的偏移，格式为hh:mi[:ss]时表示周期开始当天的时刻，为空不限制',
+  `sla_finish` varchar(16) NOT NULL DEFAULT '' COMMENT '最晚完成时间，格式同最晚开始时间',
+  `sla_duration` bigint(20) NOT NULL DEFAULT '0' COMMENT '最长执行时间，单位 秒，0不限制',
+  `exec_mode` tinyint(4) NOT NULL DEFAULT '0' COMMENT '执行方式 0.普通 1.扇出，标准输出的json数组按属性fanout_template指定的模板任务生成子任务 2.传感器，按属性sensor_interval反复执行检查命令直至成功或超过属性sensor_timeout',
   `priority` smallint(6) NOT NULL DEFAULT '0',
   `disabled` tinyint(4) NOT NULL DEFAULT '0',
   `task_time_out` bigint(20) DEFAULT '0' COMMENT '超时时间',
   `task_start` bigint(20) DEFAULT NULL COMMENT '周期内启动时间，格式 mm-dd hh24:mi:ss，最大单位小于调度周期',
+  `task_start_list` varchar(256) NOT NULL DEFAULT '' COMMENT '周期内的多个启动时间，逗号分隔，格式 [月份偏移:]秒数偏移，设置后代替task_start',
   `task_cmd` varchar(2048) NOT NULL COMMENT '任务命令行',
   `task_desc` varchar(500) DEFAULT NULL COMMENT '任务说明',
   `create_user_id` varchar(30) DEFAULT '' COMMENT '创建人',
@@ -155,19 +233,41 @@ CREATE TABLE `scd_task_log` (
   `batch_task_id` varchar(128) NOT NULL COMMENT '任务批次id，规则作业批次id+任务id',
   `batch_job_id` varchar(128) NOT NULL COMMENT '作业批次id，规则 批次id+作业id',
   `batch_id` varchar(128) NOT NULL COMMENT '批次ID，规则scheduleId + 周期开始时间(不含周期内启动时间)',
+  `attempt` int(11) NOT NULL DEFAULT '1' COMMENT '第几次执行，每次重试单独记录一条日志',
   `start_time` datetime DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP COMMENT '开始时间',
   `end_time` datetime DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP COMMENT '结束时间',
-  `state` varchar(1) DEFAULT NULL COMMENT '状态 0.初始状态 1. 执行中 2. 暂停 3. 完成 4.忽略 5.意外中止',
-  `batch_type` varchar(1) NOT NULL COMMENT '执行类型 1. 自动定时调度 2.手动人工调度 3.修复执行',
+  `state` varchar(1) DEFAULT NULL COMMENT '状态 0.初始状态 1. 执行中 2. 暂停 3. 完成 4.意外中止 5.忽略 6.等待 7.跳过',
+  `batch_type` varchar(1) NOT NULL COMMENT '执行类型 1. 自动定时调度 2.手动人工调度 3.修复执行 4.错过补执行 5.回填执行 6.依赖汇合执行 7.webhook触发执行 8.文件到达触发执行',
   `stdout` text NOT NULL COMMENT '标准输出',
   `stderr` text NOT NULL COMMENT '标准输出（错误）',
   `errmsg` text NOT NULL COMMENT '调度错误信息',
+  `err_type` tinyint(4) NOT NULL DEFAULT '0' COMMENT '失败类型 0.无 1.执行模块无法连接 2.RPC调用错误 3.超时 4.命令返回非0 5.被信号结束 6.程序异常 7.其它执行失败',
+  `exit_code` int(11) NOT NULL DEFAULT '-1' COMMENT '命令的退出码，http执行器为响应的状态码，未取得时为-1',
   PRIMARY KEY (`log_id`),
   KEY `task_id` (`task_id`,`batch_task_id`,`batch_job_id`,`batch_id`)
 ) ENGINE=InnoDB AUTO_INCREMENT=331642 DEFAULT CHARSET=utf8 COMMENT='任务执行信息表：\n           日志部分，记录任务执行情况。';
 
 /*Data for the table `scd_task_log` */
 
+/*Table structure for table `scd_task_result` */
+
+DROP TABLE IF EXISTS `scd_task_result`;
+
+CREATE TABLE `scd_task_result` (
+  `result_id` bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT '自增id',
+  `batch_task_id` varchar(128) NOT NULL COMMENT '任务批次id',
+  `batch_id` varchar(128) NOT NULL COMMENT '批次ID',
+  `task_id` bigint(20) NOT NULL COMMENT '任务id',
+  `result_key` varchar(128) NOT NULL COMMENT '结果名称',
+  `result_value` text NOT NULL COMMENT '结果值',
+  `create_time` datetime NOT NULL COMMENT '创建时间',
+  PRIMARY KEY (`result_id`),
+  KEY `batch_task_id` (`batch_task_id`),
+  KEY `batch_id` (`batch_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='任务结果表：\n           日志部分，记录任务在标准输出中以##result key=value发布的结果，供同批次中的下级任务使用。';
+
+/*Data for the table `scd_task_result` */
+
 /*Table structure for table `scd_task_rel` */
 
 DROP TABLE IF EXISTS `scd_task_rel`;
@@ -0,0 +1,55 @@
+package schedule
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+//告警事件结构
+type AlertEvent struct { // {{{
+	Type         string    //事件类型 timeout.调度执行超时 quarantine.调度依赖关系错误被隔离 reltimeout.等待其它调度中的依赖任务超时 taskfail.任务执行失败 slamiss.未达成SLA
+	ScheduleId   int64     //调度ID
+	ScheduleName string    //调度名称
+	BatchId      string    //批次ID
+	TaskId       int64     //任务ID，调度级别的事件为0
+	TaskName     string    //任务名称
+	ErrType      string    //任务的失败类型，见errTypes
+	ExitCode     int       //任务命令的退出码
+	Message      string    //事件说明
+	Time         time.Time //事件发生时间
+} // }}}
+
+//Alert输出告警事件。事件会写入日志，若设置了告警地址，
+//同时将事件以json格式POST至告警地址。
+func Alert(ev *AlertEvent) { // {{{
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	g.L.Warnf("[Alert] type=%s schedule=[%d %s] batchId=[%s] task=[%d %s] %s\n", ev.Type,
+		ev.ScheduleId, ev.ScheduleName, ev.BatchId, ev.TaskId, ev.TaskName, ev.Message)
+
+	if g.AlertUrl == "" {
+		return
+	}
+
+	go func() {
+		b, err := json.Marshal(ev)
+		if err != nil {
+			g.L.Warnf("[Alert] marshal event error %s\n", err.Error())
+			return
+		}
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Post(g.AlertUrl, "application/json", bytes.NewReader(b))
+		if err != nil {
+			g.L.Warnf("[Alert] post event to [%s] error %s\n", g.AlertUrl, err.Error())
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			g.L.Warnf("[Alert] post event to [%s] status %s\n", g.AlertUrl, resp.Status)
+		}
+	}()
+} // }}}
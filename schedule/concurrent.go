@@ -0,0 +1,130 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+var (
+	//全部调度中正在执行的任务实例
+	instances = newTaskInstances()
+)
+
+//任务实例登记结构，记录每个任务正在执行的实例，
+//用来限制同一任务同时执行的实例数量。
+type taskInstances struct { // {{{
+	lock    sync.Mutex
+	cond    *sync.Cond
+	running map[string][]*ExecTask //执行中的任务实例，key见ExecTask.instanceKey
+} // }}}
+
+func newTaskInstances() *taskInstances { // {{{
+	ti := &taskInstances{running: make(map[string][]*ExecTask)}
+	ti.cond = sync.NewCond(&ti.lock)
+	return ti
+} // }}}
+
+//acquire按任务的最大实例数量及处理方式为任务执行结构登记一个执行实例。
+//实例数量未达到上限时直接登记并返回true。达到上限时：
+//  0.跳过本次执行，任务状态设置为7(跳过)，按失败处理，返回false
+//  1.排队等待，任务状态设置为6(等待)，直到有实例结束后登记，任务被中止时返回false
+//  2.中止之前最早的执行实例，失败类型记录为8(canceled)，然后登记
+//错过补执行及回填执行的批次执行的是历史周期，不跳过也不中止其它实例，一律排队等待。
+//处理结果记录在任务的错误信息中。
+func (ti *taskInstances) acquire(et *ExecTask) bool { // {{{
+	t := et.task
+	key := et.instanceKey()
+	ti.lock.Lock()
+	if t.Concurrent <= 0 || len(ti.running[key]) < t.Concurrent {
+		ti.running[key] = append(ti.running[key], et)
+		ti.lock.Unlock()
+		return true
+	}
+
+	overlap := t.Overlap
+	if bt := et.execJob.execType; bt == 4 || bt == 5 {
+		overlap = 1
+	}
+
+	switch overlap {
+	case 1:
+		//排队等待
+		et.state = 6
+		et.errstr = fmt.Sprintf("queued, %d instances running.", len(ti.running[key]))
+		ti.lock.Unlock()
+		if err := et.Log(); err != nil {
+			g.L.Warnf("[ti.acquire] %s\n", err.Error())
+		}
+		g.L.Infoln("task", t.Name, "is queued batchTaskId[", et.batchTaskId, "]")
+
+		ti.lock.Lock()
+		for !et.aborted && len(ti.running[key]) >= t.Concurrent {
+			ti.cond.Wait()
+		}
+		if et.aborted {
+			ti.lock.Unlock()
+			return false
+		}
+		ti.running[key] = append(ti.running[key], et)
+		ti.lock.Unlock()
+		return true
+	case 2:
+		//中止之前的执行
+		n := len(ti.running[key]) - t.Concurrent + 1
+		olds := append([]*ExecTask{}, ti.running[key][:n]...)
+		ti.running[key] = append(ti.running[key][n:], et)
+		ti.lock.Unlock()
+
+		for _, old := range olds {
+			old.abort(8, fmt.Sprintf("canceled by batchTaskId[%s].", et.batchTaskId))
+			go old.kill()
+			g.L.Infoln("task", t.Name, "batchTaskId[", old.batchTaskId, "] is canceled by batchTaskId[", et.batchTaskId, "]")
+		}
+		et.errstr = fmt.Sprintf("canceled %d previous instances.", len(olds))
+		return true
+	default:
+		//跳过本次执行
+		et.state = 7
+		et.errstr = fmt.Sprintf("skipped, %d instances running.", len(ti.running[key]))
+		ti.lock.Unlock()
+		g.L.Infoln("task", t.Name, "is skipped batchTaskId[", et.batchTaskId, "]")
+		return false
+	}
+} // }}}
+
+//release移除任务执行结构登记的执行实例，并唤醒排队等待的任务
+func (ti *taskInstances) release(et *ExecTask) { // {{{
+	key := et.instanceKey()
+	ti.lock.Lock()
+	defer ti.lock.Unlock()
+
+	ets := ti.running[key]
+	for i, e := range ets {
+		if e == et {
+			ti.running[key] = append(ets[:i], ets[i+1:]...)
+			break
+		}
+	}
+	if len(ti.running[key]) == 0 {
+		delete(ti.running, key)
+	}
+	ti.cond.Broadcast()
+} // }}}
+
+//instanceKey返回限制同时执行的实例数量时任务执行结构的标识，一般为任务ID。
+//扇出生成的子任务按模板任务ID及元素序号区分，同一批次中的子任务互不限制。
+func (et *ExecTask) instanceKey() string { // {{{
+	if et.instance != "" {
+		return et.instance
+	}
+	return strconv.FormatInt(et.task.Id, 10)
+} // }}}
+
+//wake唤醒排队等待的任务，用于任务被中止后结束等待
+func (ti *taskInstances) wake() { // {{{
+	ti.lock.Lock()
+	defer ti.lock.Unlock()
+
+	ti.cond.Broadcast()
+} // }}}
@@ -0,0 +1,103 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+//达到最大实例数量时，定时批次按Overlap跳过，补执行及回填批次排队等待已有实例结束
+func TestAcquireByExecType(t *testing.T) { // {{{
+	useTestDB(t)
+
+	cases := []struct {
+		name     string
+		execType int8
+		overlap  int8
+		queued   bool
+	}{
+		{"auto skip", 1, 0, false},
+		{"misfire", 4, 0, true},
+		{"backfill", 5, 0, true},
+		{"backfill no cancel", 5, 2, true},
+	}
+	for i, c := range cases {
+		task := &Task{Id: int64(100 + i), Name: c.name, Concurrent: 1, Overlap: c.overlap}
+		running := ExecTaskWarper(&ExecJob{batchJobId: "r.1", batchId: "r", execType: 1}, task)
+		if !instances.acquire(running) {
+			t.Fatalf("%s: first instance not acquired", c.name)
+		}
+
+		et := ExecTaskWarper(&ExecJob{batchJobId: "b.1", batchId: "b", execType: c.execType}, task)
+		done := make(chan bool, 1)
+		go func() { done <- instances.acquire(et) }()
+
+		if !c.queued {
+			if ok := <-done; ok {
+				instances.release(et)
+				t.Errorf("%s: acquired while limit reached, want skipped", c.name)
+			}
+			instances.release(running)
+			continue
+		}
+
+		select {
+		case <-done:
+			t.Errorf("%s: acquired before the running instance ended, want queued", c.name)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if running.aborted {
+			t.Errorf("%s: running instance was canceled", c.name)
+		}
+		instances.release(running)
+		select {
+		case ok := <-done:
+			if !ok {
+				t.Errorf("%s: queued instance not acquired after release", c.name)
+			}
+			instances.release(et)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: queued instance still waiting after release", c.name)
+		}
+	}
+} // }}}
+
+//跳过的任务按失败处理，下级任务不会按成功继续执行；中止之前的实例时记录为被新实例中止
+func TestAcquireSkipAndCancel(t *testing.T) { // {{{
+	useTestDB(t)
+	ej := &ExecJob{batchJobId: "b.1", batchId: "b", execType: 1}
+
+	skip := &Task{Id: 200, Name: "skip", Executor: "schedule", Concurrent: 1, Overlap: 0}
+	running := ExecTaskWarper(ej, skip)
+	if !instances.acquire(running) {
+		t.Fatalf("first instance not acquired")
+	}
+	skipped := ExecTaskWarper(ej, skip)
+	if instances.acquire(skipped) {
+		instances.release(skipped)
+		t.Fatalf("acquired while limit reached, want skipped")
+	}
+	instances.release(running)
+	if skipped.state != 7 {
+		t.Errorf("skipped state = %d, want 7", skipped.state)
+	}
+	next := ExecTaskWarper(ej, &Task{Id: 201, Name: "next"})
+	next.relExecTasks[skipped.id] = skipped
+	next.relDone(skipped)
+	if next.relFailCnt != 1 || next.state != 2 {
+		t.Errorf("downstream of skipped task: relFailCnt = %d, state = %d, want 1, 2", next.relFailCnt, next.state)
+	}
+
+	cancel := &Task{Id: 202, Name: "cancel", Executor: "schedule", Concurrent: 1, Overlap: 2}
+	old := ExecTaskWarper(ej, cancel)
+	if !instances.acquire(old) {
+		t.Fatalf("first instance not acquired")
+	}
+	et := ExecTaskWarper(ej, cancel)
+	if !instances.acquire(et) {
+		t.Fatalf("new instance not acquired, want previous canceled")
+	}
+	instances.release(et)
+	if !old.aborted || old.errType != 8 {
+		t.Errorf("previous instance aborted = %v, errType = %d, want true, 8", old.aborted, old.errType)
+	}
+} // }}}
@@ -0,0 +1,106 @@
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CycleSchedule 按调度周期计算启动时间，周期取值为 ss 秒 mi 分钟 h 小时
+// d 日 w 周 m 月 q 季度 y 年。一个周期内可以有多个启动时间，每个启动时间
+// 由相对周期开始时间的月份偏移及时间偏移组成。
+type CycleSchedule struct {
+	Cyc         string          //调度周期
+	StartMonth  []int           //周期内启动时间的月份偏移
+	StartSecond []time.Duration //周期内启动时间的时间偏移
+}
+
+// Cycle 根据周期及周期内启动时间构建CycleSchedule，启动时间会按先后排序。
+// 周期不支持或启动时间数量不匹配时返回error信息。
+func Cycle(cyc string, sm []int, ss []time.Duration) (*CycleSchedule, error) { // {{{
+	if !isCycle(cyc) {
+		return nil, fmt.Errorf("unsupported cycle [%s]", cyc)
+	}
+	if len(sm) != len(ss) {
+		return nil, fmt.Errorf("cycle [%s] start month and start second count not match", cyc)
+	}
+
+	cs := &CycleSchedule{
+		Cyc:         cyc,
+		StartMonth:  append([]int{}, sm...),
+		StartSecond: append([]time.Duration{}, ss...),
+	}
+	if len(cs.StartSecond) == 0 {
+		cs.StartMonth, cs.StartSecond = []int{0}, []time.Duration{0}
+	}
+	sort.Sort(cs)
+
+	return cs, nil
+} // }}}
+
+// ParseStartList 解析周期内的启动时间列表，多个启动时间以逗号分隔，
+// 每个启动时间格式为 [月份偏移:]秒数偏移，如 "0:3600,1:7200" 表示周期
+// 开始后第1小时，以及周期开始的次月再过2小时。
+func ParseStartList(spec string) ([]int, []time.Duration, error) { // {{{
+	sm, ss := make([]int, 0), make([]time.Duration, 0)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		month, sec := "0", item
+		if i := strings.Index(item, ":"); i >= 0 {
+			month, sec = item[:i], item[i+1:]
+		}
+		m, err := strconv.Atoi(strings.TrimSpace(month))
+		if err != nil || m < 0 {
+			return nil, nil, fmt.Errorf("invalid start month [%s] in [%s]", month, spec)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(sec), 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil, fmt.Errorf("invalid start second [%s] in [%s]", sec, spec)
+		}
+		sm, ss = append(sm, m), append(ss, time.Duration(n)*time.Second)
+	}
+
+	return sm, ss, nil
+} // }}}
+
+// Next 返回给定时间之后的下一个启动时间。
+func (cs *CycleSchedule) Next(t time.Time) time.Time { // {{{
+	//按周期取整
+	s := TruncDate(cs.Cyc, t)
+	for i, st := range cs.StartSecond {
+		if n := s.AddDate(0, cs.StartMonth[i], 0).Add(st); n.After(t) {
+			return n
+		}
+	}
+
+	//当前周期内的启动时间全部小于给定时间，从下一周期开始
+	s = AddCycle(cs.Cyc, s, 1)
+	return s.AddDate(0, cs.StartMonth[0], 0).Add(cs.StartSecond[0])
+} // }}}
+
+func (cs *CycleSchedule) Len() int { return len(cs.StartSecond) }
+func (cs *CycleSchedule) Swap(i, j int) {
+	cs.StartMonth[i], cs.StartMonth[j] = cs.StartMonth[j], cs.StartMonth[i]
+	cs.StartSecond[i], cs.StartSecond[j] = cs.StartSecond[j], cs.StartSecond[i]
+}
+func (cs *CycleSchedule) Less(i, j int) bool {
+	if cs.StartMonth[i] == cs.StartMonth[j] {
+		return cs.StartSecond[i] < cs.StartSecond[j]
+	}
+	return cs.StartMonth[i] < cs.StartMonth[j]
+}
+
+//isCycle判断是否支持的调度周期
+func isCycle(cyc string) bool { // {{{
+	switch cyc {
+	case "ss", "mi", "h", "d", "w", "m", "q", "y":
+		return true
+	}
+	return false
+} // }}}
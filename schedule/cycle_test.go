@@ -0,0 +1,175 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+//date按本地时区构造时间
+func date(y int, m time.Month, d, h, mi, s int) time.Time {
+	return time.Date(y, m, d, h, mi, s, 0, time.Local)
+}
+
+func TestCycleNext(t *testing.T) { // {{{
+	cases := []struct {
+		name string
+		cyc  string
+		sm   []int
+		ss   []time.Duration
+		now  time.Time
+		want time.Time
+	}{
+		{"minute", "mi", nil, nil, date(2026, 1, 10, 8, 30, 15), date(2026, 1, 10, 8, 31, 0)},
+		{"minute offset", "mi", []int{0}, []time.Duration{30 * time.Second}, date(2026, 1, 10, 8, 30, 15), date(2026, 1, 10, 8, 30, 30)},
+		{"hour at start", "h", nil, nil, date(2026, 1, 10, 8, 0, 0), date(2026, 1, 10, 9, 0, 0)},
+		{"day before start", "d", []int{0}, []time.Duration{2 * time.Hour}, date(2026, 1, 10, 1, 0, 0), date(2026, 1, 10, 2, 0, 0)},
+		{"day after start", "d", []int{0}, []time.Duration{2 * time.Hour}, date(2026, 1, 10, 3, 0, 0), date(2026, 1, 11, 2, 0, 0)},
+		{"day multi", "d", []int{0, 0}, []time.Duration{18 * time.Hour, 6 * time.Hour}, date(2026, 1, 10, 7, 0, 0), date(2026, 1, 10, 18, 0, 0)},
+		{"week", "w", []int{0}, []time.Duration{24 * time.Hour}, date(2026, 1, 10, 0, 0, 0), date(2026, 1, 12, 0, 0, 0)},
+		{"month end of year", "m", []int{0}, []time.Duration{time.Hour}, date(2026, 12, 20, 0, 0, 0), date(2027, 1, 1, 1, 0, 0)},
+		{"quarter month offset", "q", []int{1}, []time.Duration{0}, date(2026, 5, 10, 0, 0, 0), date(2026, 8, 1, 0, 0, 0)},
+		{"quarter before offset", "q", []int{2}, []time.Duration{0}, date(2026, 4, 10, 0, 0, 0), date(2026, 6, 1, 0, 0, 0)},
+		{"year", "y", []int{5}, []time.Duration{0}, date(2026, 7, 1, 0, 0, 0), date(2027, 6, 1, 0, 0, 0)},
+	}
+	for _, c := range cases {
+		cs, err := Cycle(c.cyc, c.sm, c.ss)
+		if err != nil {
+			t.Fatalf("%s: Cycle() error %s", c.name, err.Error())
+		}
+		if got := cs.Next(c.now); !got.Equal(c.want) {
+			t.Errorf("%s: Next(%s) = %s, want %s", c.name, c.now, got, c.want)
+		}
+	}
+
+	if _, err := Cycle("x", nil, nil); err == nil {
+		t.Errorf("Cycle(x) = nil error, want unsupported cycle")
+	}
+	if _, err := Cycle("d", []int{0}, nil); err == nil {
+		t.Errorf("Cycle() with mismatched starts = nil error, want error")
+	}
+} // }}}
+
+func TestAddCycle(t *testing.T) { // {{{
+	base := date(2026, 1, 15, 10, 0, 0)
+	cases := []struct {
+		cyc  string
+		n    int
+		want time.Time
+	}{
+		{"ss", 30, date(2026, 1, 15, 10, 0, 30)},
+		{"mi", -5, date(2026, 1, 15, 9, 55, 0)},
+		{"h", 2, date(2026, 1, 15, 12, 0, 0)},
+		{"d", 17, date(2026, 2, 1, 10, 0, 0)},
+		{"w", -1, date(2026, 1, 8, 10, 0, 0)},
+		{"m", 2, date(2026, 3, 15, 10, 0, 0)},
+		{"q", 1, date(2026, 4, 15, 10, 0, 0)},
+		{"q", -1, date(2025, 10, 15, 10, 0, 0)},
+		{"y", 1, date(2027, 1, 15, 10, 0, 0)},
+		{"x", 1, base},
+	}
+	for _, c := range cases {
+		if got := AddCycle(c.cyc, base, c.n); !got.Equal(c.want) {
+			t.Errorf("AddCycle(%s, %s, %d) = %s, want %s", c.cyc, base, c.n, got, c.want)
+		}
+	}
+} // }}}
+
+func TestTruncDate(t *testing.T) { // {{{
+	now := date(2026, 8, 13, 10, 20, 30)
+	cases := []struct {
+		cyc  string
+		now  time.Time
+		want time.Time
+	}{
+		{"ss", now, now},
+		{"mi", now, date(2026, 8, 13, 10, 20, 0)},
+		{"h", now, date(2026, 8, 13, 10, 0, 0)},
+		{"d", now, date(2026, 8, 13, 0, 0, 0)},
+		{"w", now, date(2026, 8, 9, 0, 0, 0)},
+		{"m", now, date(2026, 8, 1, 0, 0, 0)},
+		{"q", now, date(2026, 7, 1, 0, 0, 0)},
+		{"q", date(2026, 1, 1, 0, 0, 0), date(2026, 1, 1, 0, 0, 0)},
+		{"q", date(2026, 3, 31, 23, 59, 59), date(2026, 1, 1, 0, 0, 0)},
+		{"q", date(2026, 4, 1, 0, 0, 0), date(2026, 4, 1, 0, 0, 0)},
+		{"q", date(2026, 12, 31, 0, 0, 0), date(2026, 10, 1, 0, 0, 0)},
+		{"y", now, date(2026, 1, 1, 0, 0, 0)},
+	}
+	for _, c := range cases {
+		if got := TruncDate(c.cyc, c.now); !got.Equal(c.want) {
+			t.Errorf("TruncDate(%s, %s) = %s, want %s", c.cyc, c.now, got, c.want)
+		}
+	}
+} // }}}
+
+func TestParseStartList(t *testing.T) { // {{{
+	cases := []struct {
+		spec string
+		sm   []int
+		ss   []time.Duration
+		err  bool
+	}{
+		{"", []int{}, []time.Duration{}, false},
+		{"3600", []int{0}, []time.Duration{time.Hour}, false},
+		{"0:3600, 1:7200", []int{0, 1}, []time.Duration{time.Hour, 2 * time.Hour}, false},
+		{"2:0,", []int{2}, []time.Duration{0}, false},
+		{"x:1", nil, nil, true},
+		{"1:x", nil, nil, true},
+		{"-1:0", nil, nil, true},
+		{"0:-5", nil, nil, true},
+	}
+	for _, c := range cases {
+		sm, ss, err := ParseStartList(c.spec)
+		if (err != nil) != c.err {
+			t.Errorf("ParseStartList(%q) error = %v, want error %v", c.spec, err, c.err)
+			continue
+		}
+		if c.err {
+			continue
+		}
+		if len(sm) != len(c.sm) || len(ss) != len(c.ss) {
+			t.Errorf("ParseStartList(%q) = %v, %v, want %v, %v", c.spec, sm, ss, c.sm, c.ss)
+			continue
+		}
+		for i := range sm {
+			if sm[i] != c.sm[i] || ss[i] != c.ss[i] {
+				t.Errorf("ParseStartList(%q) = %v, %v, want %v, %v", c.spec, sm, ss, c.sm, c.ss)
+				break
+			}
+		}
+	}
+} // }}}
+
+//任务设置了StartList时按其中的多个启动时间计算，否则按StartSecond计算
+func TestTaskStartList(t *testing.T) { // {{{
+	cases := []struct {
+		name string
+		task *Task
+		now  time.Time
+		want []time.Time
+	}{
+		{"start second", &Task{Id: 1, TaskType: 1, TaskCyc: "d", StartSecond: time.Hour},
+			date(2026, 1, 10, 0, 30, 0), []time.Time{date(2026, 1, 10, 1, 0, 0), date(2026, 1, 11, 1, 0, 0)}},
+		{"day list", &Task{Id: 2, TaskType: 1, TaskCyc: "d", StartList: "64800,21600"},
+			date(2026, 1, 10, 0, 0, 0), []time.Time{date(2026, 1, 10, 6, 0, 0), date(2026, 1, 10, 18, 0, 0), date(2026, 1, 11, 6, 0, 0)}},
+		{"quarter months", &Task{Id: 3, TaskType: 1, TaskCyc: "q", StartList: "0:3600,2:0"},
+			date(2026, 1, 1, 0, 0, 0), []time.Time{date(2026, 1, 1, 1, 0, 0), date(2026, 3, 1, 0, 0, 0), date(2026, 4, 1, 1, 0, 0)}},
+	}
+	for _, c := range cases {
+		if err := c.task.setTimer(); err != nil {
+			t.Fatalf("%s: setTimer() error %s", c.name, err.Error())
+		}
+		now := c.now
+		for i, want := range c.want {
+			got := c.task.NextTime(now)
+			if !got.Equal(want) {
+				t.Errorf("%s: run %d = %s, want %s", c.name, i, got, want)
+			}
+			now = got
+		}
+	}
+
+	bad := &Task{Id: 4, TaskType: 1, TaskCyc: "d", StartList: "a"}
+	if err := bad.setTimer(); err == nil {
+		t.Errorf("setTimer() with invalid StartList = nil error, want error")
+	}
+} // }}}
@@ -13,9 +13,15 @@ func (sl *ScheduleManager) getAllSchedules() error { // {{{
 	sql := `SELECT scd.id,
 				scd.scd_name,
 				scd.scd_num,
+				scd.scd_run_num,
 				scd.scd_cyc,
+				scd.scd_start_list,
 				scd.scd_timeout,
+				scd.scd_sla_start,
+				scd.scd_sla_finish,
+				scd.scd_sla_duration,
 				scd.scd_desc,
+				scd.scd_token,
 				scd.create_user_id,
 				scd.create_time,
 				scd.modify_user_id,
@@ -33,9 +39,10 @@ func (sl *ScheduleManager) getAllSchedules() error { // {{{
 			Jobs:  make([]*Job, 0),
 			Tasks: make([]*Task, 0),
 		}
-		err = rows.Scan(&scd.Id, &scd.Name, &scd.Count, &scd.Cyc, &scd.TimeOut,
-			&scd.Desc, &scd.CreateUserId, &scd.CreateTime, &scd.ModifyUserId,
+		err = rows.Scan(&scd.Id, &scd.Name, &scd.Count, &scd.RunCount, &scd.Cyc, &scd.StartList, &scd.TimeOut,
+			&scd.SlaStart, &scd.SlaFinish, &scd.SlaDuration, &scd.Desc, &scd.Token, &scd.CreateUserId, &scd.CreateTime, &scd.ModifyUserId,
 			&scd.ModifyTime)
+		scd.setState()
 
 		sl.ScheduleList = append(sl.ScheduleList, scd)
 	}
@@ -47,12 +54,12 @@ func (sl *ScheduleManager) getAllSchedules() error { // {{{
 func (s *Schedule) add() error { // {{{
 
 	sql := `INSERT INTO scd_schedule
-            (scd_name, scd_num, scd_cyc,
-             scd_timeout,  scd_desc, create_user_id,
+            (scd_name, scd_num, scd_cyc, scd_start_list,
+             scd_timeout, scd_sla_start, scd_sla_finish, scd_sla_duration, scd_desc, create_user_id,
              create_time, modify_user_id, modify_time)
-		VALUES      ( ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	result, err := g.HiveConn.Exec(sql, &s.Name, &s.Count, &s.Cyc,
-		&s.TimeOut, &s.Desc, &s.CreateUserId, &s.CreateTime, &s.ModifyUserId, &s.ModifyTime)
+		VALUES      ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := g.HiveConn.Exec(sql, &s.Name, &s.Count, &s.Cyc, &s.StartList,
+		&s.TimeOut, &s.SlaStart, &s.SlaFinish, &s.SlaDuration, &s.Desc, &s.CreateUserId, &s.CreateTime, &s.ModifyUserId, &s.ModifyTime)
 	if err != nil {
 		e := fmt.Sprintf("[s.add] Query sql [%s] error %s.\n", sql, err.Error())
 		return errors.New(e)
@@ -70,15 +77,19 @@ func (s *Schedule) update() error { // {{{
 		SET  scd_name=?,
              scd_num=?,
              scd_cyc=?,
+             scd_start_list=?,
              scd_timeout=?,
+             scd_sla_start=?,
+             scd_sla_finish=?,
+             scd_sla_duration=?,
              scd_desc=?,
              create_user_id=?,
              create_time=?,
              modify_user_id=?,
              modify_time=?
 		 WHERE id=?`
-	_, err := g.HiveConn.Exec(sql, &s.Name, &s.Count, &s.Cyc,
-		&s.TimeOut, &s.Desc, &s.CreateUserId, &s.CreateTime, &s.ModifyUserId, &s.ModifyTime, &s.Id)
+	_, err := g.HiveConn.Exec(sql, &s.Name, &s.Count, &s.Cyc, &s.StartList,
+		&s.TimeOut, &s.SlaStart, &s.SlaFinish, &s.SlaDuration, &s.Desc, &s.CreateUserId, &s.CreateTime, &s.ModifyUserId, &s.ModifyTime, &s.Id)
 	if err != nil {
 		e := fmt.Sprintf("[s.update] Query sql [%s] error %s.\n", sql, err.Error())
 		return errors.New(e)
@@ -88,6 +99,32 @@ func (s *Schedule) update() error { // {{{
 	return err
 } // }}}
 
+//updateRunCount将Schedule已执行次数更新到元数据库。
+func (s *Schedule) updateRunCount() error { // {{{
+	sql := `UPDATE scd_schedule SET scd_run_num=? WHERE id=?`
+	_, err := g.HiveConn.Exec(sql, &s.RunCount, &s.Id)
+	if err != nil {
+		e := fmt.Sprintf("[s.updateRunCount] Query sql [%s] error %s.\n", sql, err.Error())
+		return errors.New(e)
+	}
+	g.L.Debugln("[s.updateRunCount] schedule", s.Id, "runCount", s.RunCount, "\nsql=", sql)
+
+	return err
+} // }}}
+
+//updateToken将Schedule的webhook令牌更新到元数据库。
+func (s *Schedule) updateToken() error { // {{{
+	sql := `UPDATE scd_schedule SET scd_token=? WHERE id=?`
+	_, err := g.HiveConn.Exec(sql, &s.Token, &s.Id)
+	if err != nil {
+		e := fmt.Sprintf("[s.updateToken] Query sql [%s] error %s.\n", sql, err.Error())
+		return errors.New(e)
+	}
+	g.L.Debugln("[s.updateToken] schedule", s.Id, "\nsql=", sql)
+
+	return err
+} // }}}
+
 //Delete方法，删除元数据库中的调度信息
 func (s *Schedule) deleteSchedule() error { // {{{
 	sql := `Delete FROM scd_schedule WHERE id=?`
@@ -116,9 +153,15 @@ func (s *Schedule) getSchedule() error { // {{{
 	sql := `SELECT scd.id,
 				scd.scd_name,
 				scd.scd_num,
+				scd.scd_run_num,
 				scd.scd_cyc,
+				scd.scd_start_list,
 				scd.scd_timeout,
+				scd.scd_sla_start,
+				scd.scd_sla_finish,
+				scd.scd_sla_duration,
 				scd.scd_desc,
+				scd.scd_token,
                 scd.create_user_id,
                 scd.create_time,
                 scd.modify_user_id,
@@ -136,8 +179,9 @@ func (s *Schedule) getSchedule() error { // {{{
 	//s.StartSecond = make([]time.Duration, 0)
 	//循环读取记录，格式化后存入变量ｂ
 	for rows.Next() {
-		err = rows.Scan(&id, &s.Name, &s.Count, &s.Cyc,
-			&s.TimeOut, &s.Desc, &s.CreateUserId, &s.CreateTime, &s.ModifyUserId, &s.ModifyTime)
+		err = rows.Scan(&id, &s.Name, &s.Count, &s.RunCount, &s.Cyc, &s.StartList,
+			&s.TimeOut, &s.SlaStart, &s.SlaFinish, &s.SlaDuration, &s.Desc, &s.Token, &s.CreateUserId, &s.CreateTime, &s.ModifyUserId, &s.ModifyTime)
+		s.setState()
 		//s.setStart()
 		if err != nil {
 			e := fmt.Sprintf("getSchedule error %s\n", err.Error())
@@ -341,8 +385,23 @@ func (t *Task) getTask() error { // {{{
 			   task.task_start,
 			   task.disabled,
 			   task.priority,
+			   task.misfire,
+			   task.overlap,
+			   task.rel_wait_time,
+			   task.trigger_rule,
+			   task.join_window,
+			   task.executor,
+			   task.exec_mode,
+			   task.retry_delay,
+			   task.retry_backoff,
+			   task.retry_max_delay,
+			   task.retry_on,
+			   task.sla_start,
+			   task.sla_finish,
+			   task.sla_duration,
 			   task.task_desc,
 			   task.task_start,
+			   task.task_start_list,
 			   task.task_cmd,
                task.create_user_id,
                task.create_time,
@@ -358,7 +417,7 @@ func (t *Task) getTask() error { // {{{
 
 	//循环读取记录，格式化后存入变量ｂ
 	for rows.Next() {
-		err = rows.Scan(&id, &t.Address, &t.Name, &t.TimeOut, &t.TaskType, &t.TaskCyc, &t.Cronstr, &t.Retry, &t.Concurrent, &t.StartSecond, &t.Disabled, &t.Priority, &t.Desc, &td, &t.Cmd, &t.CreateUserId, &t.CreateTime, &t.ModifyUserId, &t.ModifyTime)
+		err = rows.Scan(&id, &t.Address, &t.Name, &t.TimeOut, &t.TaskType, &t.TaskCyc, &t.Cronstr, &t.Retry, &t.Concurrent, &t.StartSecond, &t.Disabled, &t.Priority, &t.Misfire, &t.Overlap, &t.RelWaitTime, &t.TriggerRule, &t.JoinWindow, &t.Executor, &t.ExecMode, &t.RetryDelay, &t.Backoff, &t.MaxDelay, &t.RetryOn, &t.SlaStart, &t.SlaFinish, &t.SlaDuration, &t.Desc, &td, &t.StartList, &t.Cmd, &t.CreateUserId, &t.CreateTime, &t.ModifyUserId, &t.ModifyTime)
 		if err != nil {
 			e := fmt.Sprintf("\n[t.getTask] %s.", err.Error())
 			return errors.New(e)
@@ -441,15 +500,30 @@ func (t *Task) update() error { // {{{
 				cronstr=?,
 				retry=?,
 				concurrent=?,
+				misfire=?,
+				overlap=?,
+				rel_wait_time=?,
+				trigger_rule=?,
+				join_window=?,
+				executor=?,
+				exec_mode=?,
+				retry_delay=?,
+				retry_backoff=?,
+				retry_max_delay=?,
+				retry_on=?,
+				sla_start=?,
+				sla_finish=?,
+				sla_duration=?,
 				task_time_out=?,
 				task_start=?,
+				task_start_list=?,
 				task_type=?,
 				task_cmd=?,
 				task_desc=?,
 				modify_user_id=?,
 				modify_time=?
 			WHERE id=?`
-	_, err := g.HiveConn.Exec(sql, &t.Address, &t.Name, &t.TaskCyc, &t.Cronstr, &t.Retry, &t.Concurrent, &t.TimeOut, &t.StartSecond, &t.TaskType, &t.Cmd, &t.Desc, &t.ModifyUserId, &t.ModifyTime, &t.Id)
+	_, err := g.HiveConn.Exec(sql, &t.Address, &t.Name, &t.TaskCyc, &t.Cronstr, &t.Retry, &t.Concurrent, &t.Misfire, &t.Overlap, &t.RelWaitTime, &t.TriggerRule, &t.JoinWindow, &t.Executor, &t.ExecMode, &t.RetryDelay, &t.Backoff, &t.MaxDelay, &t.RetryOn, &t.SlaStart, &t.SlaFinish, &t.SlaDuration, &t.TimeOut, &t.StartSecond, &t.StartList, &t.TaskType, &t.Cmd, &t.Desc, &t.ModifyUserId, &t.ModifyTime, &t.Id)
 	if err != nil {
 		e := fmt.Sprintf("\n[t.update] sql %s error %s.", sql, err.Error())
 		return errors.New(e)
@@ -487,12 +561,12 @@ func (t *Task) getNewRelTaskId() (int64, error) { // {{{
 func (t *Task) add() (err error) { // {{{
 
 	sql := `INSERT INTO scd_task
-            (task_address, task_name, job_id,task_cyc,cronstr,retry,concurrent,
-             task_time_out, task_start, task_type,
+            (task_address, task_name, job_id,task_cyc,cronstr,retry,concurrent,misfire,overlap,rel_wait_time,trigger_rule,join_window,executor,exec_mode,retry_delay,retry_backoff,retry_max_delay,retry_on,sla_start,sla_finish,sla_duration,
+             task_time_out, task_start, task_start_list, task_type,
              task_cmd, task_desc, create_user_id, create_time,
              modify_user_id, modify_time)
-			VALUES      (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,?)`
-	result, err := g.HiveConn.Exec(sql, &t.Address, &t.Name, &t.JobId, &t.TaskCyc, &t.Cronstr, &t.Retry, &t.Concurrent, &t.TimeOut, &t.StartSecond, &t.TaskType, &t.Cmd, &t.Desc, &t.CreateUserId, &t.CreateTime, &t.ModifyUserId, &t.ModifyTime)
+			VALUES      (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,?)`
+	result, err := g.HiveConn.Exec(sql, &t.Address, &t.Name, &t.JobId, &t.TaskCyc, &t.Cronstr, &t.Retry, &t.Concurrent, &t.Misfire, &t.Overlap, &t.RelWaitTime, &t.TriggerRule, &t.JoinWindow, &t.Executor, &t.ExecMode, &t.RetryDelay, &t.Backoff, &t.MaxDelay, &t.RetryOn, &t.SlaStart, &t.SlaFinish, &t.SlaDuration, &t.TimeOut, &t.StartSecond, &t.StartList, &t.TaskType, &t.Cmd, &t.Desc, &t.CreateUserId, &t.CreateTime, &t.ModifyUserId, &t.ModifyTime)
 	if err != nil {
 		e := fmt.Sprintf("\n[t.add] sql %s error %s.", sql, err.Error())
 		return errors.New(e)
@@ -544,6 +618,78 @@ func (t *Task) getRelJobId() (int64, error) { // {{{
 	return id + 1, err
 } // }}}
 
+//getLastRunTime从执行日志中获取任务最后一次执行的批次对应的启动时间，
+//任务没有执行日志时返回所属调度最后一次的启动时间，都没有则返回零值。
+//scd_task_log的start_time在更新日志时会被改写，因此取批次的run_time，
+//早期没有run_time的批次使用调度日志的start_time。
+func (t *Task) getLastRunTime(scdId int64) (time.Time, error) { // {{{
+	var last *time.Time
+	sql := `SELECT max(COALESCE(sl.run_time, sl.start_time))
+			FROM scd_task_log tl
+			JOIN scd_schedule_log sl ON sl.batch_id=tl.batch_id
+			WHERE tl.task_id=?`
+	rows, err := g.LogConn.Query(sql, t.Id)
+	if err != nil {
+		e := fmt.Sprintf("\n[t.getLastRunTime] sql %s error %s.", sql, err.Error())
+		return time.Time{}, errors.New(e)
+	}
+	for rows.Next() {
+		if err = rows.Scan(&last); err != nil {
+			e := fmt.Sprintf("\n[t.getLastRunTime] %s.", err.Error())
+			return time.Time{}, errors.New(e)
+		}
+	}
+	if last != nil {
+		return *last, nil
+	}
+
+	sql = `SELECT max(COALESCE(run_time, start_time))
+			FROM scd_schedule_log
+			WHERE scd_id=?`
+	rows, err = g.LogConn.Query(sql, scdId)
+	if err != nil {
+		e := fmt.Sprintf("\n[t.getLastRunTime] sql %s error %s.", sql, err.Error())
+		return time.Time{}, errors.New(e)
+	}
+	for rows.Next() {
+		if err = rows.Scan(&last); err != nil {
+			e := fmt.Sprintf("\n[t.getLastRunTime] %s.", err.Error())
+			return time.Time{}, errors.New(e)
+		}
+	}
+	if last != nil {
+		return *last, nil
+	}
+
+	return time.Time{}, nil
+} // }}}
+
+//isRelTaskDone从执行日志判断依赖的任务在start至end之间启动的批次中是否已执行成功。
+//批次的启动时间取调度日志中的run_time，未记录时取start_time。
+func isRelTaskDone(relid int64, start, end time.Time) (bool, error) { // {{{
+	var cnt int
+	sql := `SELECT count(*)
+			FROM scd_task_log tl, scd_schedule_log sl
+			WHERE tl.batch_id=sl.batch_id
+			  AND tl.task_id=?
+			  AND tl.state IN ('3', '5')
+			  AND COALESCE(sl.run_time, sl.start_time)>=?
+			  AND COALESCE(sl.run_time, sl.start_time)<?`
+	rows, err := g.LogConn.Query(sql, relid, start, end)
+	if err != nil {
+		e := fmt.Sprintf("\n[isRelTaskDone] sql %s error %s.", sql, err.Error())
+		return false, errors.New(e)
+	}
+	for rows.Next() {
+		if err = rows.Scan(&cnt); err != nil {
+			e := fmt.Sprintf("\n[isRelTaskDone] %s.", err.Error())
+			return false, errors.New(e)
+		}
+	}
+
+	return cnt > 0, nil
+} // }}}
+
 //删除依赖任务至元数据库
 func (t *Task) deleteRelTask(id int64) error { // {{{
 	sql := `DELETE FROM scd_task_rel WHERE task_id=? and rel_task_id=?`
@@ -578,6 +724,7 @@ func (s *ExecSchedule) Log() (err error) { // {{{
 		sql := `INSERT INTO scd_schedule_log
 						(batch_id,
 						 scd_id,
+						 run_time,
 						 start_time,
 						 end_time,
 						 state,
@@ -589,8 +736,9 @@ func (s *ExecSchedule) Log() (err error) { // {{{
 						 ?,
 						 ?,
 						 ?,
+						 ?,
 						 ?)`
-		result, err := g.LogConn.Exec(sql, &s.batchId, &s.schedule.Id, &s.startTime, &s.endTime, &s.state, &s.result, &s.execType)
+		result, err := g.LogConn.Exec(sql, &s.batchId, &s.schedule.Id, &s.runTime, &s.startTime, &s.endTime, &s.state, &s.result, &s.execType)
 		if err == nil {
 			Id, _ := result.LastInsertId()
 			s.LogId = int(Id)
@@ -652,33 +800,7 @@ func (j *ExecJob) Log() (err error) { // {{{
 //保存执行日志
 func (t *ExecTask) Log() (err error) { // {{{
 	if t.state == 0 {
-		sql := `INSERT INTO scd_task_log
-						(batch_task_id,batch_job_id,batch_id,
-						 task_id,
-						 start_time,
-						 end_time,
-						 state,
-						 batch_type,
-						 stdout,
-						 stderr,
-						 errmsg)
-			VALUES      (?,
-						 ?,
-						 ?,
-						 ?,
-						 ?,
-						 ?,
-						 ?,
-						 ?,
-						 ?,
-						 ?,
-						 ?)`
-		result, err := g.LogConn.Exec(sql, &t.batchTaskId, &t.batchJobId, &t.batchId, &t.task.Id, &t.startTime, &t.endTime, &t.state, &t.execType, &t.output, &t.stderr, &t.errstr)
-		if err == nil {
-			Id, _ := result.LastInsertId()
-			t.LogId = int(Id)
-		}
-		return err
+		return t.insertLog()
 	} else {
 		sql := `UPDATE scd_task_log
 						 set start_time=?,
@@ -686,14 +808,298 @@ func (t *ExecTask) Log() (err error) { // {{{
 						 state=?,
 						 stdout=?,
 						 stderr=?,
-						 errmsg=?
+						 errmsg=?,
+						 err_type=?,
+						 exit_code=?
 				WHERE log_id=?`
-		_, err = g.LogConn.Exec(sql, &t.startTime, &t.endTime, &t.state, &t.output, &t.stderr, &t.errstr, &t.LogId)
+		_, err = g.LogConn.Exec(sql, &t.startTime, &t.endTime, &t.state, &t.output, &t.stderr, &t.errstr, &t.errType, &t.exitCode, &t.LogId)
 	}
 
 	return err
 } // }}}
 
+//insertLog为任务的本次执行新增一条执行日志，每次重试均有单独的日志
+func (t *ExecTask) insertLog() (err error) { // {{{
+	sql := `INSERT INTO scd_task_log
+					(batch_task_id,batch_job_id,batch_id,
+					 task_id,
+					 attempt,
+					 start_time,
+					 end_time,
+					 state,
+					 batch_type,
+					 stdout,
+					 stderr,
+					 errmsg,
+					 err_type,
+					 exit_code)
+		VALUES      (?,
+					 ?,
+					 ?,
+					 ?,
+					 ?,
+					 ?,
+					 ?,
+					 ?,
+					 ?,
+					 ?,
+					 ?,
+					 ?,
+					 ?,
+					 ?)`
+	result, err := g.LogConn.Exec(sql, &t.batchTaskId, &t.batchJobId, &t.batchId, &t.task.Id, &t.attempt, &t.startTime, &t.endTime, &t.state, &t.execType, &t.output, &t.stderr, &t.errstr, &t.errType, &t.exitCode)
+	if err == nil {
+		Id, _ := result.LastInsertId()
+		t.LogId = int(Id)
+	}
+	return err
+} // }}}
+
+//GetTaskLogs从执行日志中获取任务最近limit次的执行记录，按日志id倒序
+func GetTaskLogs(taskId int64, limit int) ([]*TaskLog, error) { // {{{
+	sql := `SELECT log_id,
+				task_id,
+				batch_task_id,
+				batch_id,
+				attempt,
+				start_time,
+				end_time,
+				state,
+				batch_type,
+				err_type,
+				exit_code,
+				errmsg
+			FROM scd_task_log
+			WHERE task_id=?
+			ORDER BY log_id DESC
+			LIMIT ?`
+	rows, err := g.LogConn.Query(sql, taskId, limit)
+	if err != nil {
+		e := fmt.Sprintf("\n[GetTaskLogs] sql %s error %s.", sql, err.Error())
+		return nil, errors.New(e)
+	}
+
+	logs := make([]*TaskLog, 0)
+	for rows.Next() {
+		tl := &TaskLog{}
+		err = rows.Scan(&tl.LogId, &tl.TaskId, &tl.BatchTaskId, &tl.BatchId, &tl.Attempt, &tl.StartTime,
+			&tl.EndTime, &tl.State, &tl.ExecType, &tl.ErrType, &tl.ExitCode, &tl.ErrMsg)
+		if err != nil {
+			e := fmt.Sprintf("\n[GetTaskLogs] %s.", err.Error())
+			return nil, errors.New(e)
+		}
+		tl.ErrTypeName = errTypes[tl.ErrType]
+		logs = append(logs, tl)
+	}
+
+	return logs, nil
+} // }}}
+
+//saveResults保存任务发布的结果
+func (et *ExecTask) saveResults() error { // {{{
+	sql := `INSERT INTO scd_task_result
+					(batch_task_id,
+					 batch_id,
+					 task_id,
+					 result_key,
+					 result_value,
+					 create_time)
+		VALUES      (?, ?, ?, ?, ?, ?)`
+	now := time.Now()
+	for k, v := range et.results {
+		if _, err := g.LogConn.Exec(sql, &et.batchTaskId, &et.batchId, &et.task.Id, k, v, &now); err != nil {
+			e := fmt.Sprintf("\n[et.saveResults] sql %s error %s.", sql, err.Error())
+			return errors.New(e)
+		}
+	}
+
+	return nil
+} // }}}
+
+//getRelResults从结果表获取任务relid在start至end之间启动的批次中发布的结果，
+//多个批次发布同名结果时以最后发布的为准
+func getRelResults(relid int64, start, end time.Time) (map[string]string, error) { // {{{
+	results := make(map[string]string)
+	sql := `SELECT r.result_key,
+				r.result_value
+			FROM scd_task_result r, scd_schedule_log sl
+			WHERE r.batch_id=sl.batch_id
+			  AND r.task_id=?
+			  AND COALESCE(sl.run_time, sl.start_time)>=?
+			  AND COALESCE(sl.run_time, sl.start_time)<?
+			ORDER BY r.result_id`
+	rows, err := g.LogConn.Query(sql, relid, start, end)
+	if err != nil {
+		e := fmt.Sprintf("\n[getRelResults] sql %s error %s.", sql, err.Error())
+		return nil, errors.New(e)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k, v string
+		if err = rows.Scan(&k, &v); err != nil {
+			e := fmt.Sprintf("\n[getRelResults] %s.", err.Error())
+			return nil, errors.New(e)
+		}
+		results[k] = v
+	}
+
+	return results, nil
+} // }}}
+
+//getFileTriggers从元数据库获取全部未禁用的文件触发器
+func getFileTriggers() ([]*FileTrigger, error) { // {{{
+	sql := `SELECT id,
+				scd_id,
+				task_id,
+				watch_dir,
+				pattern,
+				debounce
+			FROM scd_file_trigger
+			WHERE disabled=0`
+	rows, err := g.HiveConn.Query(sql)
+	if err != nil {
+		e := fmt.Sprintf("\n[getFileTriggers] sql %s error %s.", sql, err.Error())
+		return nil, errors.New(e)
+	}
+
+	defer rows.Close()
+
+	fts := make([]*FileTrigger, 0)
+	for rows.Next() {
+		ft := &FileTrigger{}
+		if err = rows.Scan(&ft.Id, &ft.ScheduleId, &ft.TaskId, &ft.Dir, &ft.Pattern, &ft.Debounce); err != nil {
+			e := fmt.Sprintf("\n[getFileTriggers] %s.", err.Error())
+			return nil, errors.New(e)
+		}
+		fts = append(fts, ft)
+	}
+
+	return fts, nil
+} // }}}
+
+//isProcessed判断文件是否已被触发器处理，路径相同但大小或修改时间不同的文件视为新文件
+func (ft *FileTrigger) isProcessed(path string, size int64, mtime time.Time) (bool, error) { // {{{
+	var cnt int
+	sql := `SELECT count(*)
+			FROM scd_file_processed
+			WHERE trigger_id=?
+			  AND file_path=?
+			  AND file_size=?
+			  AND file_mtime=?`
+	rows, err := g.LogConn.Query(sql, ft.Id, path, size, mtime)
+	if err != nil {
+		e := fmt.Sprintf("\n[ft.isProcessed] sql %s error %s.", sql, err.Error())
+		return false, errors.New(e)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = rows.Scan(&cnt); err != nil {
+			e := fmt.Sprintf("\n[ft.isProcessed] %s.", err.Error())
+			return false, errors.New(e)
+		}
+	}
+
+	return cnt > 0, nil
+} // }}}
+
+//addProcessed记录触发器已处理的文件及触发的批次
+func (ft *FileTrigger) addProcessed(path string, size int64, mtime time.Time, batchId string) error { // {{{
+	sql := `INSERT INTO scd_file_processed
+					(trigger_id, file_path, file_size, file_mtime, batch_id, create_time)
+		VALUES      (?, ?, ?, ?, ?, ?)`
+	if _, err := g.LogConn.Exec(sql, ft.Id, path, size, mtime, batchId, time.Now()); err != nil {
+		e := fmt.Sprintf("\n[ft.addProcessed] sql %s error %s.", sql, err.Error())
+		return errors.New(e)
+	}
+
+	return nil
+} // }}}
+
+//addSlaMiss记录一次SLA未达成，调度级别的SLA taskId为0
+func addSlaMiss(scdId, taskId int64, batchId, batchTaskId, missType string, deadline time.Time) error { // {{{
+	sql := `INSERT INTO scd_sla_miss
+					(scd_id, task_id, batch_id, batch_task_id, miss_type, deadline, detect_time)
+		VALUES      (?, ?, ?, ?, ?, ?, ?)`
+	if _, err := g.LogConn.Exec(sql, scdId, taskId, batchId, batchTaskId, missType, deadline, time.Now()); err != nil {
+		e := fmt.Sprintf("\n[addSlaMiss] sql %s error %s.", sql, err.Error())
+		return errors.New(e)
+	}
+
+	return nil
+} // }}}
+
+//isSlaMissed判断调度或任务在截止时间的同类SLA未达成是否已经记录
+func isSlaMissed(scdId, taskId int64, missType string, deadline time.Time) (bool, error) { // {{{
+	var cnt int
+	sql := `SELECT count(*)
+			FROM scd_sla_miss
+			WHERE scd_id=? AND task_id=? AND miss_type=? AND deadline=?`
+	rows, err := g.LogConn.Query(sql, scdId, taskId, missType, deadline)
+	if err != nil {
+		e := fmt.Sprintf("\n[isSlaMissed] sql %s error %s.", sql, err.Error())
+		return false, errors.New(e)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = rows.Scan(&cnt); err != nil {
+			e := fmt.Sprintf("\n[isSlaMissed] %s.", err.Error())
+			return false, errors.New(e)
+		}
+	}
+
+	return cnt > 0, nil
+} // }}}
+
+//getBatchCnt获取调度在[start, end)周期内的批次数量，按批次对应的启动时间匹配周期
+func getBatchCnt(scdId int64, start, end time.Time) (int, error) { // {{{
+	var cnt int
+	sql := `SELECT count(*)
+			FROM scd_schedule_log
+			WHERE scd_id=?
+			  AND COALESCE(run_time, start_time)>=?
+			  AND COALESCE(run_time, start_time)<?`
+	rows, err := g.LogConn.Query(sql, scdId, start, end)
+	if err != nil {
+		e := fmt.Sprintf("\n[getBatchCnt] sql %s error %s.", sql, err.Error())
+		return 0, errors.New(e)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = rows.Scan(&cnt); err != nil {
+			e := fmt.Sprintf("\n[getBatchCnt] %s.", err.Error())
+			return 0, errors.New(e)
+		}
+	}
+
+	return cnt, nil
+} // }}}
+
+//getBatchRunTime从调度日志中获取批次对应的启动时间，
+//早期的批次没有记录run_time，使用批次的开始时间
+func getBatchRunTime(batchId string) (time.Time, error) { // {{{
+	var runTime *time.Time
+	sql := `SELECT COALESCE(run_time, start_time)
+			FROM scd_schedule_log
+			WHERE batch_id=?`
+	rows, err := g.LogConn.Query(sql, batchId)
+	if err != nil {
+		e := fmt.Sprintf("\n[getBatchRunTime] sql %s error %s.", sql, err.Error())
+		return time.Time{}, errors.New(e)
+	}
+	for rows.Next() {
+		if err = rows.Scan(&runTime); err != nil {
+			e := fmt.Sprintf("\n[getBatchRunTime] %s.", err.Error())
+			return time.Time{}, errors.New(e)
+		}
+	}
+	if runTime == nil {
+		e := fmt.Sprintf("\n[getBatchRunTime] not found run_time of batch [%s].", batchId)
+		return time.Time{}, errors.New(e)
+	}
+
+	return *runTime, nil
+} // }}}
+
 //getSuccessTaskId会根据传入的batchId从元数据库查找出执行成功的task
 func getSuccessTaskId(batchId string) []int64 { // {{{
 
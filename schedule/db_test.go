@@ -0,0 +1,108 @@
+package schedule
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"github.com/Sirupsen/logrus"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//测试用的数据库驱动，执行的语句全部成功，查询按testRows中设置的结果返回，
+//未设置的查询返回空结果。
+func init() {
+	sql.Register("scdtest", testDriver{})
+}
+
+var (
+	testLock sync.Mutex
+	//查询结果，key为查询语句中包含的表名
+	testRows = make(map[string]*testResult)
+	//执行过的语句
+	testExecs []string
+	//执行语句时返回的错误，key为语句中包含的表名
+	testErrs = make(map[string]error)
+)
+
+type testResult struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+type testDriver struct{}
+type testConn struct{}
+type testStmt struct{ query string }
+type testRowsIter struct {
+	res *testResult
+	pos int
+}
+
+func (testDriver) Open(string) (driver.Conn, error) { return testConn{}, nil }
+
+func (testConn) Prepare(query string) (driver.Stmt, error) { return &testStmt{query}, nil }
+func (testConn) Close() error                              { return nil }
+func (testConn) Begin() (driver.Tx, error)                 { return testConn{}, nil }
+func (testConn) Commit() error                             { return nil }
+func (testConn) Rollback() error                           { return nil }
+
+func (s *testStmt) Close() error  { return nil }
+func (s *testStmt) NumInput() int { return -1 }
+
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	testLock.Lock()
+	defer testLock.Unlock()
+	testExecs = append(testExecs, s.query)
+	for table, err := range testErrs {
+		if strings.Contains(s.query, table) {
+			return nil, err
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	testLock.Lock()
+	defer testLock.Unlock()
+	for table, res := range testRows {
+		if strings.Contains(s.query, table) {
+			return &testRowsIter{res: res}, nil
+		}
+	}
+	return &testRowsIter{res: &testResult{}}, nil
+}
+
+func (r *testRowsIter) Columns() []string { return r.res.columns }
+func (r *testRowsIter) Close() error      { return nil }
+
+func (r *testRowsIter) Next(dest []driver.Value) error {
+	if r.pos >= len(r.res.values) {
+		return io.EOF
+	}
+	copy(dest, r.res.values[r.pos])
+	r.pos++
+	return nil
+}
+
+//useTestDB将全局配置替换为使用测试驱动的配置，测试结束后恢复
+func useTestDB(t *testing.T) { // {{{
+	db, err := sql.Open("scdtest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := g
+	g = &GlobalConfigStruct{L: logrus.New(), HiveConn: db, LogConn: db, Schedules: &ScheduleManager{}}
+	g.L.Out = io.Discard
+
+	testLock.Lock()
+	testRows = make(map[string]*testResult)
+	testExecs = nil
+	testErrs = make(map[string]error)
+	testLock.Unlock()
+
+	t.Cleanup(func() {
+		g = old
+		db.Close()
+	})
+} // }}}
@@ -0,0 +1,129 @@
+package schedule
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	//全部调度共用的任务分发器
+	dispatch = newDispatcher()
+)
+
+//任务分发器，任务每次发送给执行模块前需要从分发器获取执行名额，
+//控制全局以及每个执行地址同时执行的任务数量。名额不足时任务进入队列，
+//名额释放后按任务优先级从高到低、等待时间从长到短的顺序分配。
+type dispatcher struct { // {{{
+	lock        sync.Mutex
+	running     int                //执行中的任务数量
+	addrRunning map[string]int     //每个执行地址执行中的任务数量
+	queue       []*dispatchRequest //等待执行名额的任务
+} // }}}
+
+//等待执行名额的请求
+type dispatchRequest struct { // {{{
+	et    *ExecTask //任务执行结构
+	since time.Time //开始等待的时间
+	ready chan bool //分配名额后写入true，取消等待写入false
+} // }}}
+
+func newDispatcher() *dispatcher { // {{{
+	return &dispatcher{
+		addrRunning: make(map[string]int),
+		queue:       make([]*dispatchRequest, 0),
+	}
+} // }}}
+
+//acquire为任务获取一个执行名额，名额不足时阻塞等待。
+//获取成功返回true，等待被取消时返回false。
+func (d *dispatcher) acquire(et *ExecTask) bool { // {{{
+	d.lock.Lock()
+	if len(d.queue) == 0 && d.available(et.task.Address) {
+		d.take(et.task.Address)
+		d.lock.Unlock()
+		return true
+	}
+
+	req := &dispatchRequest{et: et, since: time.Now(), ready: make(chan bool, 1)}
+	d.queue = append(d.queue, req)
+	g.L.Debugln("task", et.task.Name, "is waiting for dispatch batchTaskId[", et.batchTaskId,
+		"] running=", d.running, "address running=", d.addrRunning[et.task.Address])
+	//队列中排在前面的任务可能因执行地址名额不足而等待，尝试为其它任务分配名额
+	d.dispatch()
+	d.lock.Unlock()
+
+	return <-req.ready
+} // }}}
+
+//release释放任务的执行名额，并为等待中的任务分配名额
+func (d *dispatcher) release(et *ExecTask) { // {{{
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	addr := et.task.Address
+	d.running--
+	if d.addrRunning[addr]--; d.addrRunning[addr] <= 0 {
+		delete(d.addrRunning, addr)
+	}
+	d.dispatch()
+} // }}}
+
+//cancel取消任务的等待，任务不在队列中时不做处理
+func (d *dispatcher) cancel(et *ExecTask) { // {{{
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for i, req := range d.queue {
+		if req.et == et {
+			d.queue = append(d.queue[:i], d.queue[i+1:]...)
+			req.ready <- false
+			return
+		}
+	}
+} // }}}
+
+//dispatch按优先级及等待时间为队列中的任务分配名额，调用前需持有锁
+func (d *dispatcher) dispatch() { // {{{
+	sort.Sort(byPriority(d.queue))
+
+	queue := d.queue[:0]
+	for _, req := range d.queue {
+		if addr := req.et.task.Address; d.available(addr) {
+			d.take(addr)
+			req.ready <- true
+			continue
+		}
+		queue = append(queue, req)
+	}
+	d.queue = queue
+} // }}}
+
+//available判断是否有空闲的执行名额，最大数量为0时不做限制
+func (d *dispatcher) available(addr string) bool { // {{{
+	if g.MaxRunning > 0 && d.running >= g.MaxRunning {
+		return false
+	}
+	if g.MaxRunningPerWorker > 0 && d.addrRunning[addr] >= g.MaxRunningPerWorker {
+		return false
+	}
+	return true
+} // }}}
+
+//take占用一个执行名额
+func (d *dispatcher) take(addr string) { // {{{
+	d.running++
+	d.addrRunning[addr]++
+} // }}}
+
+//等待队列按优先级从高到低、等待时间从长到短排序
+type byPriority []*dispatchRequest
+
+func (q byPriority) Len() int      { return len(q) }
+func (q byPriority) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q byPriority) Less(i, j int) bool {
+	if q[i].et.task.Priority != q[j].et.task.Priority {
+		return q[i].et.task.Priority > q[j].et.task.Priority
+	}
+	return q[i].since.Before(q[j].since)
+}
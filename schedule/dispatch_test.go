@@ -0,0 +1,101 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+//newDispatchRequest构造等待名额的请求，name记录在任务名称中
+func newDispatchRequest(name, addr string, priority int16, since time.Time) *dispatchRequest { // {{{
+	et := &ExecTask{task: &Task{Name: name, Address: addr, Priority: priority}}
+	return &dispatchRequest{et: et, since: since, ready: make(chan bool, 1)}
+} // }}}
+
+//名额释放后按优先级从高到低、等待时间从长到短分配，执行地址名额不足的任务不阻塞其它任务
+func TestDispatchOrder(t *testing.T) { // {{{
+	base := time.Date(2026, 1, 10, 0, 0, 0, 0, time.Local)
+	cases := []struct {
+		name      string
+		max       int
+		perWorker int
+		busy      []string //已占用名额的执行地址
+		reqs      []*dispatchRequest
+		want      []string //每次释放名额后获得名额的任务
+	}{
+		{"priority then wait", 1, 0, []string{"a"}, []*dispatchRequest{
+			newDispatchRequest("low-old", "a", 0, base),
+			newDispatchRequest("high-new", "a", 5, base.Add(2*time.Second)),
+			newDispatchRequest("high-old", "a", 5, base.Add(time.Second)),
+			newDispatchRequest("low-new", "a", 0, base.Add(3*time.Second)),
+		}, []string{"high-old", "high-new", "low-old", "low-new"}},
+		{"worker limit", 0, 1, []string{"a"}, []*dispatchRequest{
+			newDispatchRequest("high-a", "a", 5, base),
+			newDispatchRequest("low-b", "b", 0, base.Add(time.Second)),
+		}, []string{"low-b", "high-a"}},
+	}
+
+	old := g
+	defer func() { g = old }()
+
+	for _, c := range cases {
+		g = &GlobalConfigStruct{MaxRunning: c.max, MaxRunningPerWorker: c.perWorker}
+		d := newDispatcher()
+		for _, addr := range c.busy {
+			d.take(addr)
+		}
+		d.queue = append(d.queue, c.reqs...)
+
+		got := make([]string, 0, len(c.want))
+		granted := func() {
+			for _, req := range c.reqs {
+				select {
+				case ok := <-req.ready:
+					if ok {
+						got = append(got, req.et.task.Name)
+					}
+				default:
+				}
+			}
+		}
+
+		//先分配不受占用影响的名额，再逐个释放执行地址a的名额
+		d.lock.Lock()
+		d.dispatch()
+		d.lock.Unlock()
+		granted()
+		for i := 0; i < len(c.reqs) && len(got) < len(c.want); i++ {
+			d.release(&ExecTask{task: &Task{Address: "a"}})
+			granted()
+		}
+
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: dispatch order = %v, want %v", c.name, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: dispatch order = %v, want %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+} // }}}
+
+//取消等待的任务从队列中移除并收到false
+func TestDispatchCancel(t *testing.T) { // {{{
+	old := g
+	defer func() { g = old }()
+	g = &GlobalConfigStruct{MaxRunning: 1}
+
+	d := newDispatcher()
+	d.take("a")
+	req := newDispatchRequest("t", "a", 0, time.Now())
+	d.queue = append(d.queue, req)
+
+	d.cancel(req.et)
+	if ok := <-req.ready; ok {
+		t.Errorf("cancelled request got ready = true, want false")
+	}
+	if len(d.queue) != 0 {
+		t.Errorf("queue length = %d after cancel, want 0", len(d.queue))
+	}
+} // }}}
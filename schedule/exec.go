@@ -12,13 +12,22 @@ import (
 
 //根据传入的Schedule参数来构建一个调度的执行结构，并返回。
 func ExecScheduleWarper(s *Schedule) *ExecSchedule { // {{{
+	return newExecSchedule(s, s.NextStart, 1, nil)
+} // }}}
+
+//根据传入的Schedule、启动时间、执行类型构建调度的执行结构。
+//taskIds不为空时，执行结构中只包含指定的任务，否则包含下次启动时间与runTime相同的任务。
+func newExecSchedule(s *Schedule, runTime time.Time, execType int8, taskIds map[int64]bool) *ExecSchedule { // {{{
 	return &ExecSchedule{
 		batchId:  fmt.Sprintf("%s %d", time.Now().Local().Format("2006-01-02 15:04:05.000000"), s.Id), //批次ID
 		schedule: s,
-		execType: 1,
+		runTime:  runTime,
+		execType: execType,
 		jobCnt:   s.JobCnt,
 		//taskCnt:      s.TaskCnt,
+		taskIds:      taskIds,
 		execTasks:    make(map[int64]*ExecTask), //设置任务列表
+		runTasks:     make(map[int64]*ExecTask),
 		execTaskChan: make(chan *ExecTask),
 	}
 } // }}}
@@ -28,19 +37,28 @@ type ExecSchedule struct { // {{{
 	lock           sync.Mutex
 	batchId        string              //批次ID，规则scheduleId + 周期开始时间(不含周期内启动时间)
 	schedule       *Schedule           //调度
+	runTime        time.Time           //批次对应的启动时间
 	startTime      *time.Time          //开始时间
 	endTime        *time.Time          //结束时间
 	state          int8                //状态 0.不满足条件未执行 1. 执行中 2. 暂停 3. 完成 4.意外中止
 	result         float32             //结果,调度中执行成功任务的百分比
-	execType       int8                //执行类型 1. 自动定时调度 2.手动人工调度 3.修复执行
+	execType       int8                //执行类型 1. 自动定时调度 2.手动人工调度 3.修复执行 4.错过补执行 5.回填执行 6.依赖汇合执行 7.webhook触发执行 8.文件到达触发执行
+	taskIds        map[int64]bool      //指定执行的任务，为空时执行启动时间为runTime的任务
 	execJobs       []*ExecJob          //作业执行信息
 	execTasks      map[int64]*ExecTask //任务执行信息
+	runTasks       map[int64]*ExecTask //正在执行中的任务
 	execTaskChan   chan *ExecTask      //taskChan用来传递完成的任务。当一个作业完成后会将自己放入taskChan变量中
 	jobCnt         int                 //调度中作业数量
 	taskCnt        int                 //调度中任务数量
+	totalCnt       int                 //批次中的任务总数，含扇出生成的子任务
 	successTaskCnt int                 //执行成功任务数量
 	failTaskCnt    int                 //执行失败任务数量
+	dynTaskSeq     int64               //动态生成任务的标识序号
 	LogId          int                 //调度日志Id
+
+	conf      map[string]interface{} //批次的参数，webhook触发时为请求中的json
+	slaMissed map[string]bool        //已记录的SLA未达成，避免重复告警
+	chain     []int64                //由schedule执行器触发时，触发链上各级批次所属的调度id，最早的在前
 } // }}}
 
 //初始化调度的执行结构，使之包含完整的执行链。
@@ -57,45 +75,69 @@ func (es *ExecSchedule) InitExecSchedule() (err error) { // {{{
 		return errors.New(fmt.Sprintf("\n[es.InitExecSchedule] Save Log Failed %s", err.Error()))
 	}
 
+	execJobs := make(map[int64]*ExecJob)
 	for _, j := range es.schedule.Jobs {
-		execJob := ExecJobWarper(es.batchId, j)
+		execJob := ExecJobWarper(es, j)
 		err = execJob.InitExecJob(es)
 		if err != nil {
 			return errors.New(fmt.Sprintf("\n[es.InitExecSchedule] %s", err.Error()))
 		}
 		es.execJobs = append(es.execJobs, execJob)
 		es.taskCnt = es.taskCnt + execJob.taskCnt
+		es.totalCnt = es.totalCnt + execJob.taskCnt
+		execJobs[j.Id] = execJob
 	}
-	return err
-} // }}}
 
-func (es *ExecSchedule) AddExecTask(tasks []*Task) (err error) {
+	//按作业链设置上级作业
+	for _, ej := range es.execJobs {
+		if ej.job.PreJobId > 0 {
+			ej.prevExecJob = execJobs[ej.job.PreJobId]
+		}
+	}
 
-	var i int
-	for k, ej := range es.execJobs {
-		if ej.job.Id == tasks[0].JobId {
-			i = k
+	//全部任务执行结构构建完成后，再设置任务间的依赖关系
+	for _, et := range es.execTasks {
+		if err = et.InitExecTask(es); err != nil {
+			return errors.New(fmt.Sprintf("\n[es.InitExecSchedule] %s %s", et.task.Name, err.Error()))
 		}
 	}
+	return err
+} // }}}
+
+//AddExecTask向执行中的批次加入动态生成的任务，任务加入作业ej中。
+//加入的任务使用负数作为批次中的标识，不设置依赖关系，由调用方设置。
+//返回生成的任务执行结构。
+func (es *ExecSchedule) AddExecTask(ej *ExecJob, tasks []*Task) ([]*ExecTask, error) { // {{{
 	etasks := make([]*ExecTask, 0, len(tasks))
 	for _, t := range tasks {
-		ej := es.execJobs[i]
+		es.dynTaskSeq--
 		et := ExecTaskWarper(ej, t)
-		ej.execTasks[t.Id] = et
-		es.execTasks[t.Id] = et
+		et.id = es.dynTaskSeq
+		et.batchTaskId = fmt.Sprintf("%s.%d.%d", ej.batchJobId, t.Id, -et.id)
+		if err := et.Log(); err != nil {
+			e := fmt.Sprintf("\n[es.AddExecTask] %s %s", t.Name, err.Error())
+			return etasks, errors.New(e)
+		}
+
+		ej.execTasks[et.id] = et
+		es.execTasks[et.id] = et
 		es.taskCnt = es.taskCnt + 1
+		es.totalCnt = es.totalCnt + 1
 		ej.taskCnt = ej.taskCnt + 1
+		ej.totalCnt = ej.totalCnt + 1
 		etasks = append(etasks, et)
 	}
 
-	for _, et := range etasks {
-		if err = et.InitExecTask(es); err != nil {
-			e := fmt.Sprintf("\n[ej.InitExecTask] %s %s", et.task.Name, err.Error())
-			return errors.New(e)
-		}
+	return etasks, nil
+} // }}}
+
+//判断任务是否需要在本批次中执行
+func (es *ExecSchedule) includeTask(t *Task) bool { // {{{
+	if es.taskIds != nil {
+		return es.taskIds[t.Id]
 	}
-	return nil
-}
+	return t.NextRunTime.Equal(es.runTime)
+} // }}}
 
 //ExecSchedule执行前状态记录
 func (es *ExecSchedule) Start() (err error) { // {{{
@@ -114,15 +156,23 @@ func (es *ExecSchedule) Start() (err error) { // {{{
 //当调度中全部任务完成后，将调度执行体从全局列表中移除，并设置下次启动时间。
 func (es *ExecSchedule) TaskDone(et *ExecTask) (finish bool, err error) { // {{{
 
-	//计算任务完成百分比
+	//按本批次的任务总数计算任务完成百分比
 	s := es.schedule
-	es.result = float32(s.TaskCnt-es.taskCnt) / float32(s.TaskCnt)
+	if es.totalCnt > 0 {
+		es.result = float32(es.totalCnt-es.taskCnt) / float32(es.totalCnt)
+	}
 	if es.taskCnt == 0 { //调度结束
-		//g.Schedules.RemoveExecSchedule(es.batchId)
-		s.delExecSchChan <- es.batchId
+		g.Schedules.RemoveExecSchedule(es.batchId)
 		//全部完成后，写入日志存储至数据库，设置下次启动时间
 		es.endTime = NowTimePtr()
 		es.state = 3
+		es.checkSla()
+		//自动定时调度计入调度的已执行次数
+		if es.execType == 1 {
+			if err = s.runDone(); err != nil {
+				g.L.Warnf("[es.TaskDone] %s\n", err.Error())
+			}
+		}
 		if err = es.Log(); err != nil {
 			es.state = 4
 			return true, errors.New(fmt.Sprintf("\n[es.TaskDone] %s", err.Error()))
@@ -133,6 +183,7 @@ func (es *ExecSchedule) TaskDone(et *ExecTask) (finish bool, err error) { // {{{
 
 		return true, nil
 	}
+	es.checkSla()
 
 	return false, err
 } // }}}
@@ -146,6 +197,10 @@ func (es *ExecSchedule) TaskDone(et *ExecTask) (finish bool, err error) { // {{{
 //全部执行结束后，设置Schedule的下次启动时间。
 func (es *ExecSchedule) Run() { // {{{
 	var err error
+	//自动定时调度的批次由Timer计入执行中的数量，无论如何结束都要减去
+	if es.execType == 1 {
+		defer es.schedule.addRunning(-1)
+	}
 	defer func() {
 		if err := recover(); err != nil {
 			var buf bytes.Buffer
@@ -162,29 +217,60 @@ func (es *ExecSchedule) Run() { // {{{
 		g.L.Warningln(fmt.Sprintf("\n[es.Run] %s", err.Error()))
 		return
 	}
+	//批次中没有需要执行的任务，直接结束
+	if es.taskCnt == 0 {
+		if _, err = es.TaskDone(nil); err != nil {
+			g.L.Warningln(fmt.Sprintf("\n[es.Run] %s", err.Error()))
+		}
+		return
+	}
+
+	//设置了最大执行时间时，超时后中止批次
+	var timeout <-chan time.Time
+	if es.schedule.TimeOut > 0 {
+		timeout = time.After(time.Duration(es.schedule.TimeOut) * time.Second)
+	}
+
+	//定时检查SLA，执行中的任务超过截止时间时也能及时发现
+	slaTick := time.NewTicker(slaCheckInterval)
+	defer slaTick.Stop()
+
 	//不断轮询taskChan中的信息，直到最后一个任务完成
 	//调用执行结构的Timer方法，并退出线程。
 	for {
 		select {
+		case <-timeout:
+			if err = es.Abort(); err != nil {
+				g.L.Warningln(fmt.Sprintf("\n[es.Run] %s", err.Error()))
+			}
+			es.checkSla()
+			return
+		case <-slaTick.C:
+			es.checkSla()
 		case et := <-es.execTaskChan:
 			es.taskCnt--
-
-			//将该任务从其它任务的依赖列表中删除。
-			for _, et1 := range es.execTasks {
-
-				//任务执行失败，将依赖的下级任务状态设置为2（暂停）
-				if et.state != 3 && et.state != 5 {
-					if _, ok := et1.relExecTasks[et.task.Id]; ok && et1.state != 2 {
-						et1.state = 2
-					}
+			delete(es.runTasks, et.id)
+
+			//扇出任务执行成功后，按输出生成子任务
+			if et.task.ExecMode == 1 && et.state == 3 {
+				if err = es.fanOut(et); err != nil {
+					g.L.Warningln(fmt.Sprintf("\n[es.Run] %s", err.Error()))
+					et.state = 4
+					et.errstr = err.Error()
+					et.Log()
 				}
+			}
 
-				delete(et1.relExecTasks, et.task.Id)
-				delete(et1.nextExecTasks, et.task.Id)
+			//将该任务从其它任务的依赖列表中删除，并按下级任务的触发规则设置其状态。
+			for _, et1 := range es.execTasks {
+				et1.relDone(et)
+				delete(et1.nextExecTasks, et.id)
 			}
 
 			if et.state == 3 || et.state == 5 { //任务执行成功或可以忽略
 				es.successTaskCnt++
+				//通知依赖该任务汇合启动的任务
+				es.joinDone(et)
 			} else if et.state == 2 {
 				es.failTaskCnt++ //暂停的也计入失败数量
 				g.L.Debugln("task", et.task.Name, "is pause batchTaskId[", et.batchTaskId, "] state=", et.state)
@@ -193,9 +279,9 @@ func (es *ExecSchedule) Run() { // {{{
 				g.L.Debugln("task", et.task.Name, "is fail batchTaskId[", et.batchTaskId, "] state=", et.state)
 			}
 
+			//作业日志写入失败时作业状态为4，下级作业按失败的作业处理，批次继续执行
 			if err = et.execJob.TaskDone(et); err != nil {
 				g.L.Warningln(fmt.Sprintf("\n[es.Run] %s", err.Error()))
-				return
 			}
 
 			finish := false
@@ -220,8 +306,17 @@ func (es *ExecSchedule) Run() { // {{{
 func (es *ExecSchedule) RunTasks() (err error) { // {{{
 	//启动独立的任务
 	for _, et := range es.execTasks {
-		//依赖任务列表为空，任务可以执行
-		if len(et.relExecTasks) == 0 && (et.state == 0 || et.state == 2) {
+		//依赖的任务满足触发规则，任务可以执行
+		if et.ready() && (et.state == 0 || et.state == 2 || et.state == 5) {
+
+			//上级作业未结束时等待，上级作业失败阻塞时任务设置为暂停
+			switch et.execJob.prevState() {
+			case 0:
+				continue
+			case 2:
+				et.execJob.blocked = true
+				et.state = 2
+			}
 
 			//任务所属作业开始时间为空，设置作业启动信息
 			if err = et.execJob.Start(); err != nil {
@@ -230,7 +325,8 @@ func (es *ExecSchedule) RunTasks() (err error) { // {{{
 			}
 
 			//将该任务从任务列表中删除。
-			delete(es.execTasks, et.task.Id)
+			delete(es.execTasks, et.id)
+			es.runTasks[et.id] = et
 
 			//执行任务，完成后任务会放入taskChan中
 			go et.Run(es.execTaskChan)
@@ -250,6 +346,67 @@ func (es *ExecSchedule) Pause() { // {{{
 
 } // }}}
 
+//Abort中止调度执行，在批次超过调度的最大执行时间后调用。
+//未执行的任务设置为意外中止，执行中的任务通知执行模块结束进程，
+//然后写入任务、作业、调度的最终日志，并发出告警。
+func (es *ExecSchedule) Abort() (err error) { // {{{
+	es.lock.Lock()
+	defer es.lock.Unlock()
+
+	s := es.schedule
+	g.L.Warnf("[es.Abort] schedule [%d %s] batchId=[%s] timeout %d seconds, abort.\n",
+		s.Id, s.Name, es.batchId, s.TimeOut)
+
+	msg := fmt.Sprintf("schedule timeout %d seconds, aborted.", s.TimeOut)
+	//未执行的任务
+	for _, et := range es.execTasks {
+		et.abort(3, msg)
+	}
+	//执行中的任务
+	for _, et := range es.runTasks {
+		et.abort(3, msg)
+		dispatch.cancel(et)
+		go et.kill()
+	}
+	//结束排队等待的任务
+	instances.wake()
+
+	//执行中的任务被中止后仍会放入execTaskChan，启动线程接收
+	go func(n int) {
+		for i := 0; i < n; i++ {
+			<-es.execTaskChan
+		}
+	}(len(es.runTasks))
+
+	for _, ej := range es.execJobs {
+		if ej.endTime == nil {
+			ej.endTime = NowTimePtr()
+			ej.state = 4
+			if e := ej.Log(); e != nil {
+				g.L.Warnf("[es.Abort] %s\n", e.Error())
+			}
+		}
+	}
+
+	g.Schedules.RemoveExecSchedule(es.batchId)
+	es.failTaskCnt += len(es.execTasks) + len(es.runTasks)
+	es.endTime = NowTimePtr()
+	es.state = 4
+	if err = es.Log(); err != nil {
+		err = errors.New(fmt.Sprintf("\n[es.Abort] %s", err.Error()))
+	}
+
+	Alert(&AlertEvent{
+		Type:         "timeout",
+		ScheduleId:   s.Id,
+		ScheduleName: s.Name,
+		BatchId:      es.batchId,
+		Message:      msg,
+	})
+
+	return err
+} // }}}
+
 //作业执行信息结构
 type ExecJob struct { // {{{
 	batchJobId string     //作业批次ID，批次ID + 作业ID
@@ -260,26 +417,38 @@ type ExecJob struct { // {{{
 	state      int8       //状态 0.不满足条件未执行 1. 执行中 2. 暂停 3. 完成 4.意外中止
 	result     float32    //结果执行成功任务的百分比
 	//nextJob    *ExecJob            //下一个作业
-	execType  int8                //执行类型1. 自动定时调度 2.手动人工调度 3.修复执行
-	execTasks map[int64]*ExecTask //任务执行信息
-	taskCnt   int                 //作业中任务数量
-	LogId     int                 //调度日志Id
+	execType    int8                //执行类型 1. 自动定时调度 2.手动人工调度 3.修复执行 4.错过补执行 5.回填执行 6.依赖汇合执行 7.webhook触发执行 8.文件到达触发执行
+	execTasks   map[int64]*ExecTask //任务执行信息
+	prevExecJob *ExecJob            //上级作业执行信息
+	runTime     time.Time           //批次对应的启动时间
+	taskCnt     int                 //作业中任务数量
+	totalCnt    int                 //作业在本批次中的任务总数，含扇出生成的子任务
+	failTaskCnt int                 //执行失败任务数量
+	blocked     bool                //上级作业失败，本作业未执行
+	LogId       int                 //调度日志Id
+
+	conf  map[string]interface{} //批次的参数
+	chain []int64                //触发链上各级批次所属的调度id，见ExecSchedule.chain
 } // }}}
 
-//根据传入的batchId和Job参数来构建一个调度的执行结构，并返回。
-func ExecJobWarper(batchId string, j *Job) *ExecJob { // {{{
+//根据传入的调度执行结构和Job参数来构建一个作业的执行结构，并返回。
+func ExecJobWarper(es *ExecSchedule, j *Job) *ExecJob { // {{{
 	return &ExecJob{
-		batchJobId: fmt.Sprintf("%s.%d", batchId, j.Id),
-		batchId:    batchId,
+		batchJobId: fmt.Sprintf("%s.%d", es.batchId, j.Id),
+		batchId:    es.batchId,
 		job:        j,
 		state:      0,
 		result:     0,
-		execType:   j.ExecType,
+		execType:   es.execType,
+		runTime:    es.runTime,
+		conf:       es.conf,
+		chain:      es.chain,
 		execTasks:  make(map[int64]*ExecTask, 0),
 	}
 } // }}}
 
-//初始化作业执行链，并返回。
+//初始化作业中的任务执行结构。
+//作业被禁用或本批次中没有需要执行的任务时，作业状态设置为5(忽略)。
 func (ej *ExecJob) InitExecJob(es *ExecSchedule) (err error) { // {{{
 	if err = ej.Log(); err != nil {
 		e := fmt.Sprintf("\n[ej.InitExecJob] %s %s", ej.job.Name, err.Error())
@@ -287,25 +456,57 @@ func (ej *ExecJob) InitExecJob(es *ExecSchedule) (err error) { // {{{
 	}
 
 	//构建当前作业中的任务执行结构
-	for _, t := range ej.job.Tasks { // {{{
-		et := ExecTaskWarper(ej, t)
-		if es.schedule.NextStart == t.NextRunTime {
-			ej.execTasks[t.Id] = et
-			es.execTasks[t.Id] = et
+	if ej.job.Disabled == 0 {
+		for _, t := range ej.job.Tasks {
+			if es.includeTask(t) {
+				et := ExecTaskWarper(ej, t)
+				ej.execTasks[et.id] = et
+				es.execTasks[et.id] = et
+			}
 		}
-
-	} // }}}
+	}
 	ej.taskCnt = len(ej.execTasks)
-	for _, et := range es.execTasks {
-		if err = et.InitExecTask(es); err != nil {
+	ej.totalCnt = ej.taskCnt
+
+	if ej.taskCnt == 0 {
+		ej.startTime, ej.endTime = NowTimePtr(), NowTimePtr()
+		ej.state = 5
+		if err = ej.Log(); err != nil {
 			e := fmt.Sprintf("\n[ej.InitExecJob] %s %s", ej.job.Name, err.Error())
 			return errors.New(e)
 		}
+		g.L.Debugln("job ", ej.job.Name, " is ignored ", " batchJobId[", ej.batchJobId, "] disabled=", ej.job.Disabled)
 	}
 	return nil
 
 } // }}}
 
+//prevState返回上级作业的状态，用来判断本作业中的任务是否可以执行。
+//返回值 0.上级作业未结束 1.没有上级作业或上级作业已结束 2.上级作业失败，阻塞本作业
+func (ej *ExecJob) prevState() int8 { // {{{
+	if ej.prevExecJob == nil {
+		return 1
+	}
+	return ej.prevExecJob.chainState()
+} // }}}
+
+//chainState返回作业在作业链中的状态，含义同prevState。
+//忽略的作业按其上级作业的状态处理；作业中有任务失败或结束时写入日志失败(状态4)时，
+//作业的ExecType为0则阻塞下级作业，为1则继续执行下级作业。
+func (ej *ExecJob) chainState() int8 { // {{{
+	switch ej.state {
+	case 5:
+		return ej.prevState()
+	case 3, 4:
+		failed := ej.failTaskCnt > 0 || ej.state == 4
+		if ej.blocked || (failed && ej.job.ExecType == 0) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+} // }}}
+
 //设置ExecJob的状态为开始，并记录到log中
 func (ej *ExecJob) Start() (err error) { // {{{
 	if ej.startTime == nil {
@@ -322,10 +523,15 @@ func (ej *ExecJob) Start() (err error) { // {{{
 } // }}}
 
 func (ej *ExecJob) TaskDone(et *ExecTask) (err error) { // {{{
-	delete(ej.execTasks, et.task.Id)
+	delete(ej.execTasks, et.id)
 	ej.taskCnt--
-	//计算任务完成百分比
-	ej.result = float32(ej.job.TaskCnt-ej.taskCnt) / float32(ej.job.TaskCnt)
+	if et.state != 3 && et.state != 5 {
+		ej.failTaskCnt++
+	}
+	//按作业在本批次中的任务总数计算任务完成百分比
+	if ej.totalCnt > 0 {
+		ej.result = float32(ej.totalCnt-ej.taskCnt) / float32(ej.totalCnt)
+	}
 	if ej.taskCnt == 0 { //作业结束
 		ej.endTime = NowTimePtr()
 		ej.state = 3
@@ -341,13 +547,14 @@ func (ej *ExecJob) TaskDone(et *ExecTask) (err error) { // {{{
 
 //任务执行信息结构
 type ExecTask struct { // {{{
+	id            int64      //任务执行结构在批次中的标识，一般为任务ID，动态生成的子任务为负数
 	batchTaskId   string     //任务批次ID，作业批次ID + 任务ID
 	batchJobId    string     //作业批次ID，批次ID + 作业ID
 	batchId       string     //批次ID，规则scheduleId + 周期开始时间(不含周期内启动时间)
 	task          *Task      //任务
 	startTime     *time.Time //开始时间
 	endTime       *time.Time //结束时间
-	state         int8       //状态 0.初始状态 1. 执行中 2. 暂停 3. 完成 4.意外中止 5.忽略
+	state         int8       //状态 0.初始状态 1. 执行中 2. 暂停 3. 完成 4.意外中止 5.忽略 6.等待 7.跳过
 	execType      int8       //执行类型 1. 自动定时调度 2.手动人工调度 3.修复执行
 	execJob       *ExecJob   //任务所属作业
 	output        string     //任务输出
@@ -355,13 +562,24 @@ type ExecTask struct { // {{{
 	errstr        string              //任务输出
 	nextExecTasks map[int64]*ExecTask //下级任务执行信息
 	relExecTasks  map[int64]*ExecTask //依赖的任务
+	relSuccessCnt int                 //已结束的依赖任务中成功的数量
+	relFailCnt    int                 //已结束的依赖任务中失败的数量
+	results       map[string]string   //任务发布的结果
 	LogId         int                 //调度日志Id
-	Retry         int
+	Retry         int                 //失败后的重试次数
+	attempt       int                 //当前是第几次执行，从1开始
+	aborted       bool                //任务已被中止
+	errType       int8                //失败类型，见errTypes
+	exitCode      int                 //命令的退出码，http执行器为响应的状态码，未取得时为-1
+	instance      string              //限制同时执行的实例数量时使用的标识，见instanceKey
+
+	extResults map[string]map[string]string //从结果表加载的不在本批次中的依赖任务发布的结果，key为任务名称
 } // }}}
 
 //根据传入的batchId和Job参数来构建一个调度的执行结构，并返回。
 func ExecTaskWarper(ej *ExecJob, t *Task) *ExecTask { // {{{
 	return &ExecTask{
+		id:            t.Id,
 		batchTaskId:   fmt.Sprintf("%s.%d", ej.batchJobId, t.Id),
 		batchJobId:    ej.batchJobId,
 		batchId:       ej.batchId,
@@ -369,6 +587,8 @@ func ExecTaskWarper(ej *ExecJob, t *Task) *ExecTask { // {{{
 		state:         0,
 		execType:      t.TaskType,
 		Retry:         t.Retry,
+		attempt:       1,
+		exitCode:      -1,
 		execJob:       ej,
 		relExecTasks:  make(map[int64]*ExecTask),
 		nextExecTasks: make(map[int64]*ExecTask),
@@ -383,19 +603,243 @@ func (et *ExecTask) InitExecTask(es *ExecSchedule) error { // {{{
 		return errors.New(e)
 	}
 	for _, relTask := range et.task.RelTasks {
-		retask := es.execTasks[relTask.Id]
+		if relTask == nil {
+			continue
+		}
+		//依赖的任务不在本批次中执行，不做等待
+		retask, ok := es.execTasks[relTask.Id]
+		if !ok {
+			continue
+		}
 		et.relExecTasks[relTask.Id] = retask
-		fmt.Println("rel :", relTask.Id, retask)
 		//将execTask设置为依赖任务的下级任务
-		retask.nextExecTasks[et.task.Id] = et
+		retask.nextExecTasks[et.id] = et
 	}
 	return nil
 } // }}}
 
+//relDone在依赖的任务rt结束后，将其从依赖列表中删除，并按任务的触发规则设置状态：
+//  0.全部成功 依赖的任务失败时任务设置为暂停
+//  1.全部结束 依赖的任务全部结束后执行
+//  2.任一失败 依赖的任务有一个失败即执行，全部成功时任务设置为忽略
+//  3.任一成功 依赖的任务有一个成功即执行，全部失败时任务设置为暂停
+func (et *ExecTask) relDone(rt *ExecTask) { // {{{
+	if _, ok := et.relExecTasks[rt.id]; !ok {
+		return
+	}
+	delete(et.relExecTasks, rt.id)
+
+	success := rt.state == 3 || rt.state == 5
+	if success {
+		et.relSuccessCnt++
+	} else {
+		et.relFailCnt++
+	}
+
+	switch et.task.TriggerRule {
+	case 1:
+	case 2:
+		if len(et.relExecTasks) == 0 && et.relFailCnt == 0 {
+			et.state = 5
+			et.errstr = "skipped, all reltasks success."
+		}
+	case 3:
+		if len(et.relExecTasks) == 0 && et.relSuccessCnt == 0 {
+			et.state = 2
+			et.errstr = "paused, all reltasks failed."
+		}
+	default:
+		if !success {
+			et.state = 2
+		}
+	}
+} // }}}
+
+//ready判断依赖的任务是否满足触发规则，任务可以开始执行
+func (et *ExecTask) ready() bool { // {{{
+	switch et.task.TriggerRule {
+	case 2:
+		if et.relFailCnt > 0 {
+			return true
+		}
+	case 3:
+		if et.relSuccessCnt > 0 {
+			return true
+		}
+	}
+	return len(et.relExecTasks) == 0
+} // }}}
+
+//abort将任务设置为意外中止并写入日志
+func (et *ExecTask) abort(errType int8, msg string) { // {{{
+	et.aborted = true
+	et.state = 4
+	et.errType = errType
+	et.errstr = msg
+	et.endTime = NowTimePtr()
+	if err := et.Log(); err != nil {
+		g.L.Warnf("[et.abort] %s\n", err.Error())
+	}
+} // }}}
+
+//kill通知执行模块结束任务的进程
+func (et *ExecTask) kill() { // {{{
+	switch et.task.Executor {
+	case "sql":
+		killSql(et.batchTaskId)
+		return
+	case "schedule":
+		//已启动的子调度继续执行
+		return
+	}
+
+	client, err := rpc.Dial("tcp", et.task.Address+g.Port)
+	if err != nil {
+		g.L.Warnf("[et.kill] connect task.Address[%s] error %s\n", et.task.Address+g.Port, err.Error())
+		return
+	}
+	defer client.Close()
+
+	rl := &Reply{}
+	if err = client.Call("CmdExecuter.Kill", et.batchTaskId, &rl); err != nil {
+		g.L.Warnf("[et.kill] kill task [%s] error %s\n", et.batchTaskId, err.Error())
+		return
+	}
+	if rl.Err != "" {
+		g.L.Warnf("[et.kill] kill task [%s] error %s\n", et.batchTaskId, rl.Err)
+	}
+} // }}}
+
+//waitExtTasks等待任务依赖的其它调度中的任务在本批次所属周期内执行成功。
+//周期见period，每隔extTaskPollInterval检查一次执行日志。
+//全部完成返回true；超过任务的RelWaitTime(未设置时为extTaskWaitTime)或任务被中止时返回false，
+//超时的任务设置为意外中止。
+func (et *ExecTask) waitExtTasks() bool { // {{{
+	t := et.task
+	if len(t.ExtTasksId) == 0 {
+		return true
+	}
+
+	start, end := et.period()
+	wt := extTaskWaitTime
+	if t.RelWaitTime > 0 {
+		wt = time.Duration(t.RelWaitTime) * time.Second
+	}
+	deadline := time.After(wt)
+
+	wait := make(map[int64]bool)
+	for _, id := range t.ExtTasksId {
+		wait[id] = true
+	}
+
+	logged := false
+	for {
+		for id := range wait {
+			done, err := isRelTaskDone(id, start, end)
+			if err != nil {
+				g.L.Warnf("[et.waitExtTasks] %s\n", err.Error())
+				continue
+			}
+			if done {
+				delete(wait, id)
+			}
+		}
+		if len(wait) == 0 {
+			return true
+		}
+		if et.aborted {
+			return false
+		}
+
+		if !logged {
+			et.state = 6
+			et.errstr = fmt.Sprintf("waiting for reltasks %v in period %s.", t.ExtTasksId, start.Format("2006-01-02 15:04:05"))
+			if err := et.Log(); err != nil {
+				g.L.Warnf("[et.waitExtTasks] %s\n", err.Error())
+			}
+			logged = true
+		}
+
+		select {
+		case <-deadline:
+			ids := make([]int64, 0, len(wait))
+			for id := range wait {
+				ids = append(ids, id)
+			}
+			msg := fmt.Sprintf("wait reltasks %v in period %s timeout %s.", ids,
+				start.Format("2006-01-02 15:04:05"), wt)
+			et.state = 4
+			et.errType = 3
+			et.errstr = msg
+			et.endTime = NowTimePtr()
+			if err := et.Log(); err != nil {
+				g.L.Warnf("[et.waitExtTasks] %s\n", err.Error())
+			}
+			Alert(&AlertEvent{
+				Type:       "reltimeout",
+				ScheduleId: et.execJob.job.ScheduleId,
+				BatchId:    et.batchId,
+				TaskId:     t.Id,
+				TaskName:   t.Name,
+				Message:    msg,
+			})
+			return false
+		case <-time.After(extTaskPollInterval):
+		}
+	}
+} // }}}
+
+//发送给执行模块的任务信息，与worker.Task对应
+type CmdTask struct { // {{{
+	Id          int64             //任务的ID
+	BatchId     string            //批次ID
+	BatchTaskId string            //任务批次ID，执行模块据此结束任务的进程
+	Executor    string            //执行器
+	Address     string            //任务的执行地址
+	Name        string            //任务名称
+	Cmd         string            //任务执行的命令或脚本、函数名等。
+	TimeOut     int64             //设定超时时间，0表示不做超时限制。单位秒
+	Attr        map[string]string //任务的属性信息
+	JobId       int64             //所属作业ID
+	LogicalDate time.Time         //任务处理数据的逻辑日期，即批次所属周期的开始时间
+	Attempt     int               //本次发送是第几次执行，从1开始
+	Results     map[string]string //依赖的任务发布的结果
+	Conf        string            //批次的参数，json格式
+} // }}}
+
+//cmdTask根据任务执行信息构建发送给执行模块的任务信息，命令按批次信息渲染
+func (et *ExecTask) cmdTask() (*CmdTask, error) { // {{{
+	t := et.task
+	et.loadRelResults()
+	cmd, err := et.renderCmd()
+	if err != nil {
+		e := fmt.Sprintf("\n[et.cmdTask] %s", err.Error())
+		return nil, errors.New(e)
+	}
+	start, _ := et.period()
+	return &CmdTask{
+		Id:          t.Id,
+		BatchId:     et.batchId,
+		BatchTaskId: et.batchTaskId,
+		Executor:    t.Executor,
+		Address:     t.Address,
+		Name:        t.Name,
+		Cmd:         cmd,
+		TimeOut:     t.TimeOut,
+		Attr:        t.Attr,
+		JobId:       t.JobId,
+		LogicalDate: start,
+		Results:     et.relResults(),
+		Conf:        et.confJson(),
+	}, nil
+} // }}}
+
 type Reply struct { // {{{
-	Err    string //错误信息
-	Stdout string //标准输出
-	Stderr string //标准输出
+	Err      string //错误信息
+	Stdout   string //标准输出
+	Stderr   string //标准输出
+	ErrType  int8   //失败类型，见errTypes
+	ExitCode int    //命令的退出码，http执行器为响应的状态码，未取得时为-1
 } // }}}
 
 //Run方法负责执行任务。
@@ -410,6 +854,7 @@ func (et *ExecTask) Run(taskChan chan *ExecTask) { // {{{
 			buf.Write(debug.Stack())
 			et.endTime = NowTimePtr()
 			et.state = 4
+			et.errType = 6
 			g.L.Warningln("task run error", "batchTaskId[", et.batchTaskId, "] TaskName=",
 				et.task.Name, "output=", et.output, "err=", err, " stack=", buf.String())
 			et.Log()
@@ -419,56 +864,82 @@ func (et *ExecTask) Run(taskChan chan *ExecTask) { // {{{
 		}
 	}() // }}}
 
-	//暂停状态的处理
-	if et.state == 2 {
+	//暂停、忽略状态的处理
+	if et.state == 2 || et.state == 5 {
 		et.Log()
 		taskChan <- et
 		return
 	}
 
+	//等待其它调度中依赖的任务完成
+	if !et.waitExtTasks() {
+		taskChan <- et
+		return
+	}
+
+	//任务的执行实例数量达到上限时，按任务设置的处理方式跳过、排队或中止之前的执行
+	if !instances.acquire(et) {
+		if !et.aborted {
+			et.endTime = NowTimePtr()
+			et.Log()
+		}
+		taskChan <- et
+		return
+	}
+	defer instances.release(et)
+
 	et.startTime = NowTimePtr()
+	task, err := et.cmdTask()
+	if err != nil {
+		g.L.Warningln("task", et.task.Name, "batchTaskId[", et.batchTaskId, "]", err.Error())
+		et.state = 4
+		et.errstr = err.Error()
+		et.endTime = NowTimePtr()
+		et.Log()
+		taskChan <- et
+		return
+	}
 	et.state = 1
 	et.Log()
 	g.L.Debugln("task", et.task.Name,
 		"is start batchTaskId[", et.batchTaskId, "] cmd =",
-		et.task.Cmd)
+		task.Cmd)
 
 	//执行任务
-	task := et.task
-	et.state = 3
-	var client *rpc.Client
-	var err error
-	for i := et.Retry; i > 0; i -= 1 {
-		client, err = rpc.Dial("tcp", et.task.Address+g.Port)
-		if err == nil {
-			err = client.Call("CmdExecuter.Run", task, &rl)
-			if err == nil {
-				if rl.Err != "" {
-					//et.output = rl.Err
-					et.errstr = rl.Err
-					g.L.Infoln("task", et.task.Name, "is error", rl.Err == "")
-				} else {
-					client.Close()
-					break
-				}
-			} else {
-				g.L.Errorf("task", et.task.Name, "is error", err.Error())
-			}
-			client.Close()
-		} else {
-			g.L.Errorf("connect task.Address[%s] error %s\n", et.task.Address+g.Port,
-				err.Error())
-		}
-		time.Sleep(500 * time.Millisecond)
+	if et.task.ExecMode == 2 {
+		//传感器反复检查直至条件满足，不计入重试次数
+		err = et.sense(task, rl)
+	} else {
+		//失败时按任务的重试策略重试
+		err = et.attempts(task, rl)
 	}
+	//任务已被中止，状态及日志已在中止时处理
+	if et.aborted {
+		taskChan <- et
+		return
+	}
+
+	et.state = 3
 	if err != nil || rl.Err != "" {
 		et.state = 4
+		if err != nil && et.errstr == "" {
+			et.errstr = err.Error()
+		}
 	}
 
 	et.output = rl.Stdout
 	et.stderr = rl.Stderr
 	et.endTime = NowTimePtr()
+	if et.state == 3 {
+		et.results = parseResults(rl.Stdout)
+		if err = et.saveResults(); err != nil {
+			g.L.Warningln("task", et.task.Name, "batchTaskId[", et.batchTaskId, "]", err.Error())
+		}
+	}
 	et.Log()
+	if et.state == 4 {
+		et.failAlert()
+	}
 
 	g.L.Debugln("task", et.task.Name, "is end batchTaskId[", et.batchTaskId, "] state =",
 		et.state, "StartTime", et.startTime, "EndTime", et.endTime)
@@ -477,43 +948,97 @@ func (et *ExecTask) Run(taskChan chan *ExecTask) { // {{{
 
 } // }}}
 
+//call从分发器获取执行名额后，通过RPC将任务发送给执行模块执行，完成后释放名额。
+//调度失败时err不为空，命令执行失败时rl.Err不为空，失败类型及退出码记录在任务执行结构中。
+func (et *ExecTask) call(task *CmdTask, rl *Reply) (err error) { // {{{
+	*rl = Reply{}
+	et.errType, et.exitCode = 0, -1
+	defer func() {
+		if err != nil {
+			return
+		}
+		et.exitCode = rl.ExitCode
+		if rl.Err != "" {
+			et.errstr = rl.Err
+			et.errType = rl.ErrType
+			if et.errType == 0 {
+				//调度模块中执行的任务或未返回失败类型的执行模块
+				et.errType, et.exitCode = 7, -1
+			}
+			g.L.Infoln("task", et.task.Name, "is error", rl.Err)
+		}
+	}()
+
+	//schedule执行器在调度模块中启动其它调度，等待子调度时不占用执行名额
+	if et.task.Executor == "schedule" {
+		et.runSchedule(task, rl)
+		return nil
+	}
+
+	if !dispatch.acquire(et) {
+		return errors.New(fmt.Sprintf("\n[et.call] task [%s] dispatch is canceled.", et.batchTaskId))
+	}
+	defer dispatch.release(et)
+
+	//sql执行器在调度模块中直接执行
+	if et.task.Executor == "sql" {
+		et.runSql(task, rl)
+		return nil
+	}
+
+	client, err := rpc.Dial("tcp", et.task.Address+g.Port)
+	if err != nil {
+		et.errType = 1
+		g.L.Errorf("connect task.Address[%s] error %s\n", et.task.Address+g.Port, err.Error())
+		return err
+	}
+	defer client.Close()
+
+	if err = client.Call("CmdExecuter.Run", task, rl); err != nil {
+		et.errType = 2
+		g.L.Errorf("task %s is error %s\n", et.task.Name, err.Error())
+		return err
+	}
+
+	return nil
+} // }}}
+
 //ExecSchedule.Restore(batchId string)方法修复执行指定的调度。
 //根据传入的batchId，构建调度执行结构，并调用Run方法执行其中的任务
 func Restore(batchId string, scdId int64) (err error) { // {{{
 
 	g.L.Infoln("Restore schedule by ", " batchid[", batchId, "] scdId=", scdId)
 
-	//获取执行成功的Task
-	successTaskId := getSuccessTaskId(batchId)
+	//获取批次对应的启动时间，任务命令按原批次的周期渲染，
+	//取不到时按当前时间执行
+	runTime, err := getBatchRunTime(batchId)
+	if err != nil {
+		g.L.Warnf("[Restore] %s, use current time as run time.\n", err.Error())
+		runTime, err = time.Now(), nil
+	}
 
-	//创建ExecSchedule结构
+	//只执行原批次中未成功的任务，依赖已成功任务的任务不再等待
 	s := g.Schedules.ScheduleList[scdId]
-	execSchedule := &ExecSchedule{
-		batchId:   batchId,
-		schedule:  s,
-		state:     1,
-		result:    0,
-		execType:  3,
-		jobCnt:    s.JobCnt,
-		taskCnt:   s.TaskCnt,
-		execTasks: make(map[int64]*ExecTask), //设置任务列表
+	success := make(map[int64]bool)
+	for _, id := range getSuccessTaskId(batchId) {
+		success[id] = true
 	}
-	err = execSchedule.InitExecSchedule()
-
-	//删除成功的任务
-	for _, tId := range successTaskId {
-		t := execSchedule.execTasks[tId]
-		for _, nextaks := range t.nextExecTasks {
-			delete(nextaks.relExecTasks, t.task.Id)
+	taskIds := make(map[int64]bool)
+	for _, t := range s.Tasks {
+		if !success[t.Id] {
+			taskIds[t.Id] = true
 		}
-		delete(execSchedule.execTasks, tId)
-		execSchedule.taskCnt--
 	}
 
-	//设置作业、任务的初始状态
+	//创建ExecSchedule结构，沿用原批次的批次ID
+	execSchedule := newExecSchedule(s, runTime, 3, taskIds)
+	execSchedule.batchId = batchId
+	execSchedule.state = 1
+	err = execSchedule.InitExecSchedule()
+
+	//设置作业、任务的初始状态，任务保持初始状态由Run启动
 	for _, t := range execSchedule.execTasks {
 		t.execType = 3
-		t.state = 1
 		t.execJob.execType = 3
 		t.execJob.state = 1
 	}
@@ -0,0 +1,38 @@
+package schedule
+
+import (
+	"testing"
+)
+
+//上级作业结束或写入日志失败后，下级作业按上级作业的ExecType继续执行或被阻塞
+func TestChainState(t *testing.T) { // {{{
+	cases := []struct {
+		name     string
+		state    int8
+		failCnt  int
+		execType int8
+		want     int8
+	}{
+		{"running", 1, 0, 0, 0},
+		{"success", 3, 0, 0, 1},
+		{"failed blocks", 3, 1, 0, 2},
+		{"failed continues", 3, 1, 1, 1},
+		{"log failed blocks", 4, 0, 0, 2},
+		{"log failed continues", 4, 0, 1, 1},
+	}
+	for _, c := range cases {
+		prev := &ExecJob{job: &Job{ExecType: c.execType}, state: c.state, failTaskCnt: c.failCnt}
+		ej := &ExecJob{job: &Job{}, prevExecJob: prev}
+		if got := ej.prevState(); got != c.want {
+			t.Errorf("%s: prevState() = %d, want %d", c.name, got, c.want)
+		}
+	}
+
+	//忽略的作业按其上级作业的状态处理
+	prev := &ExecJob{job: &Job{}, state: 4}
+	skip := &ExecJob{job: &Job{}, state: 5, prevExecJob: prev}
+	ej := &ExecJob{job: &Job{}, prevExecJob: skip}
+	if got := ej.prevState(); got != 2 {
+		t.Errorf("prevState() after ignored job = %d, want 2", got)
+	}
+} // }}}
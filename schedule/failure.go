@@ -0,0 +1,49 @@
+package schedule
+
+import (
+	"time"
+)
+
+//任务的失败类型
+var errTypes = map[int8]string{ // {{{
+	0: "",            //成功或未分类
+	1: "unreachable", //执行模块无法连接
+	2: "rpc",         //RPC调用错误
+	3: "timeout",     //超时
+	4: "exit",        //命令返回非0
+	5: "signal",      //被信号结束
+	6: "panic",       //程序异常
+	7: "error",       //其它执行失败，如http执行器状态码不符、sql断言失败
+	8: "canceled",    //被同一任务新的执行实例中止
+} // }}}
+
+//任务的执行记录，供管理模块查询
+type TaskLog struct { // {{{
+	LogId       int64      //日志ID
+	TaskId      int64      //任务ID
+	BatchTaskId string     //任务批次ID
+	BatchId     string     //批次ID
+	Attempt     int        //第几次执行
+	StartTime   *time.Time //开始时间
+	EndTime     *time.Time //结束时间
+	State       int8       //状态 0.初始状态 1. 执行中 2. 暂停 3. 完成 4.意外中止 5.忽略 6.等待 7.跳过
+	ExecType    int8       //执行类型
+	ErrType     int8       //失败类型，见errTypes
+	ErrTypeName string     //失败类型名称
+	ExitCode    int        //命令的退出码，http执行器为响应的状态码，未取得时为-1
+	ErrMsg      string     //错误信息
+} // }}}
+
+//failAlert任务最终执行失败时发送taskfail告警，告警中包含失败类型及退出码
+func (et *ExecTask) failAlert() { // {{{
+	Alert(&AlertEvent{
+		Type:       "taskfail",
+		ScheduleId: et.execJob.job.ScheduleId,
+		BatchId:    et.batchId,
+		TaskId:     et.task.Id,
+		TaskName:   et.task.Name,
+		ErrType:    errTypes[et.errType],
+		ExitCode:   et.exitCode,
+		Message:    et.errstr,
+	})
+} // }}}
@@ -0,0 +1,95 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//fanOut解析扇出任务的标准输出，按模板任务为其中的每个元素生成一个子任务加入批次，
+//子任务代替扇出任务成为其下级任务的依赖，下级任务按触发规则等待子任务结束，
+//扇出任务本身的成功不计入，例如任一成功的下级任务在有子任务成功后才执行。
+//没有生成子任务时仍依赖扇出任务。标准输出需为json数组，模板任务由扇出任务的属性fanout_template指定。
+func (es *ExecSchedule) fanOut(et *ExecTask) error { // {{{
+	attr := et.task.Attr["fanout_template"]
+	tid, err := strconv.ParseInt(attr, 10, 64)
+	if err != nil {
+		e := fmt.Sprintf("\n[es.fanOut] task [%d %s] invalid fanout_template [%s].", et.task.Id, et.task.Name, attr)
+		return errors.New(e)
+	}
+	tpl := es.schedule.GetTaskById(tid)
+	if tpl == nil {
+		e := fmt.Sprintf("\n[es.fanOut] task [%d %s] not found template task [%d].", et.task.Id, et.task.Name, tid)
+		return errors.New(e)
+	}
+
+	var items []interface{}
+	if err = json.Unmarshal([]byte(strings.TrimSpace(et.output)), &items); err != nil {
+		e := fmt.Sprintf("\n[es.fanOut] task [%d %s] output is not a json array. %s", et.task.Id, et.task.Name, err.Error())
+		return errors.New(e)
+	}
+
+	tasks := make([]*Task, 0, len(items))
+	for _, item := range items {
+		tasks = append(tasks, fanOutTask(tpl, item))
+	}
+	children, err := es.AddExecTask(et.execJob, tasks)
+	if err != nil {
+		e := fmt.Sprintf("\n[es.fanOut] %s", err.Error())
+		return errors.New(e)
+	}
+	//子任务与模板任务ID相同，按元素序号区分实例，避免受模板任务的并发数量限制互相跳过
+	for i, c := range children {
+		c.instance = fmt.Sprintf("%d.%d", tpl.Id, i)
+	}
+
+	for _, next := range et.nextExecTasks {
+		if len(children) == 0 {
+			continue
+		}
+		for _, c := range children {
+			next.relExecTasks[c.id] = c
+			c.nextExecTasks[next.id] = next
+		}
+		delete(next.relExecTasks, et.id)
+		delete(et.nextExecTasks, next.id)
+	}
+	g.L.Infoln("task", et.task.Name, "fan out", len(children), "tasks from template", tpl.Name,
+		"batchTaskId[", et.batchTaskId, "]")
+
+	return nil
+} // }}}
+
+//fanOutTask根据模板任务及元素生成子任务，命令中的${item}替换为元素的值，
+//元素为json对象时，${item.key}替换为对象中对应字段的值。
+//元素的值同时存入子任务的属性item中。
+func fanOutTask(tpl *Task, item interface{}) *Task { // {{{
+	t := *tpl
+	t.Attr = make(map[string]string)
+	for k, v := range tpl.Attr {
+		t.Attr[k] = v
+	}
+
+	value := itemString(item)
+	cmd := strings.Replace(tpl.Cmd, "${item}", value, -1)
+	if obj, ok := item.(map[string]interface{}); ok {
+		for k, v := range obj {
+			cmd = strings.Replace(cmd, "${item."+k+"}", itemString(v), -1)
+		}
+	}
+	t.Cmd = cmd
+	t.Attr["item"] = value
+
+	return &t
+} // }}}
+
+//itemString返回元素的字符串形式，字符串直接返回，其它类型返回json格式
+func itemString(item interface{}) string { // {{{
+	if s, ok := item.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(item)
+	return string(b)
+} // }}}
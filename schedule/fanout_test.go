@@ -0,0 +1,119 @@
+package schedule
+
+import (
+	"testing"
+)
+
+//模板任务只允许一个实例且不排队时，扇出的子任务仍应全部执行
+func TestFanOutChildrenRun(t *testing.T) { // {{{
+	useTestDB(t)
+
+	tpl := &Task{Id: 7, Name: "tpl", Cmd: "echo ${item}", Concurrent: 1, Overlap: 0}
+	ft := &Task{Id: 6, Name: "fan", ExecMode: 1, Attr: map[string]string{"fanout_template": "7"}}
+	s := &Schedule{Id: 1, Name: "s", Tasks: []*Task{ft, tpl}}
+	es := &ExecSchedule{schedule: s, batchId: "b", execTasks: make(map[int64]*ExecTask)}
+	ej := &ExecJob{batchJobId: "b.1", batchId: "b", execTasks: make(map[int64]*ExecTask)}
+
+	et := ExecTaskWarper(ej, ft)
+	et.output = `["a", "b", {"k": "c"}]`
+	if err := es.fanOut(et); err != nil {
+		t.Fatal(err)
+	}
+	if len(ej.execTasks) != 3 {
+		t.Fatalf("fan out %d tasks, want 3", len(ej.execTasks))
+	}
+
+	run := 0
+	for _, c := range ej.execTasks {
+		if !instances.acquire(c) {
+			t.Errorf("child %s is %d [%s], want running", c.batchTaskId, c.state, c.errstr)
+			continue
+		}
+		defer instances.release(c)
+		run++
+	}
+	if run != 3 {
+		t.Errorf("%d children run, want 3", run)
+	}
+
+	//同一元素的下一次执行仍受模板任务的并发数量限制
+	again := ExecTaskWarper(ej, tpl)
+	again.instance = "7.0"
+	if instances.acquire(again) {
+		instances.release(again)
+		t.Errorf("second instance of child 7.0 acquired, want skipped")
+	}
+} // }}}
+
+func TestFanOutTask(t *testing.T) { // {{{
+	tpl := &Task{Id: 7, Cmd: "load ${item} ${item.day}", Attr: map[string]string{"a": "1"}}
+	cases := []struct {
+		item interface{}
+		cmd  string
+		attr string
+	}{
+		{"x", "load x ${item.day}", "x"},
+		{float64(3), "load 3 ${item.day}", "3"},
+		{map[string]interface{}{"day": "mon"}, `load {"day":"mon"} mon`, `{"day":"mon"}`},
+	}
+	for _, c := range cases {
+		ct := fanOutTask(tpl, c.item)
+		if ct.Cmd != c.cmd || ct.Attr["item"] != c.attr || ct.Attr["a"] != "1" {
+			t.Errorf("fanOutTask(%v) = %q %v, want %q item=%q", c.item, ct.Cmd, ct.Attr, c.cmd, c.attr)
+		}
+	}
+	if _, ok := tpl.Attr["item"]; ok {
+		t.Errorf("fanOutTask modified the template attr")
+	}
+} // }}}
+
+//任一成功的下级任务等待子任务，扇出任务成功时不执行；完成百分比按含子任务的总数计算
+func TestFanOutDownstream(t *testing.T) { // {{{
+	useTestDB(t)
+
+	tpl := &Task{Id: 7, Name: "tpl", Cmd: "echo ${item}"}
+	ft := &Task{Id: 6, Name: "fan", ExecMode: 1, Attr: map[string]string{"fanout_template": "7"}}
+	next := &Task{Id: 8, Name: "next", TriggerRule: 3}
+	s := &Schedule{Id: 1, Name: "s", Tasks: []*Task{ft, tpl, next}, TaskCnt: 3}
+	es := &ExecSchedule{schedule: s, batchId: "b", execTasks: make(map[int64]*ExecTask)}
+	ej := &ExecJob{batchJobId: "b.1", batchId: "b", job: &Job{}, execTasks: make(map[int64]*ExecTask)}
+
+	fet, net := ExecTaskWarper(ej, ft), ExecTaskWarper(ej, next)
+	fet.nextExecTasks[net.id] = net
+	net.relExecTasks[fet.id] = fet
+	for _, et := range []*ExecTask{fet, net} {
+		es.execTasks[et.id], ej.execTasks[et.id] = et, et
+	}
+	es.taskCnt, es.totalCnt, ej.taskCnt, ej.totalCnt = 2, 2, 2, 2
+
+	fet.state = 3
+	fet.output = `["a", "b"]`
+	if err := es.fanOut(fet); err != nil {
+		t.Fatal(err)
+	}
+	delete(es.execTasks, fet.id)
+	es.taskCnt--
+	net.relDone(fet)
+	if net.ready() {
+		t.Errorf("downstream ready after fan out task, want waiting for children")
+	}
+	if _, err := es.TaskDone(fet); err != nil {
+		t.Fatal(err)
+	}
+	if es.result != 0.25 {
+		t.Errorf("result = %v after 1 of 4 tasks, want 0.25", es.result)
+	}
+
+	var child *ExecTask
+	for _, c := range es.execTasks {
+		if c.id < 0 {
+			child = c
+			break
+		}
+	}
+	child.state = 3
+	net.relDone(child)
+	if !net.ready() {
+		t.Errorf("downstream not ready after a child succeeded")
+	}
+} // }}}
@@ -0,0 +1,171 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	//文件触发器默认的等待时间，单位秒
+	defaultDebounce = 5
+)
+
+//文件触发器，监听调度模块所在主机的本地目录，文件名与Pattern匹配的文件
+//写入完成并在Debounce秒内不再变化后，启动调度的执行批次，执行类型为8。
+//每个文件只触发一次，已处理的文件按路径、大小及修改时间记录在scd_file_processed中，
+//重启后不再触发，同名文件再次到达且大小或修改时间不同时重新触发。
+type FileTrigger struct { // {{{
+	Id         int64  //触发器ID
+	ScheduleId int64  //调度ID
+	TaskId     int64  //任务ID，0时触发调度中全部未禁用的任务
+	Dir        string //监听的本地目录
+	Pattern    string //文件名匹配的glob表达式
+	Debounce   int64  //文件最后一次变化后等待的时间，单位秒
+} // }}}
+
+//StartFileTriggers从元数据库获取全部文件触发器并启动监听
+func (sl *ScheduleManager) StartFileTriggers() { // {{{
+	fts, err := getFileTriggers()
+	if err != nil {
+		g.L.Warningf("[sl.StartFileTriggers] %s\n", err.Error())
+		return
+	}
+
+	for _, ft := range fts {
+		if err = ft.start(); err != nil {
+			g.L.Warningf("[sl.StartFileTriggers] %s\n", err.Error())
+		}
+	}
+} // }}}
+
+//start开始监听触发器的目录，并处理停止期间到达的文件
+func (ft *FileTrigger) start() error { // {{{
+	if _, err := filepath.Match(ft.Pattern, ""); err != nil {
+		e := fmt.Sprintf("\n[ft.start] trigger [%d] invalid pattern [%s].", ft.Id, ft.Pattern)
+		return errors.New(e)
+	}
+	if ft.Debounce <= 0 {
+		ft.Debounce = defaultDebounce
+	}
+
+	events := make(chan string, 64)
+	if err := watchDir(ft.Dir, events); err != nil {
+		e := fmt.Sprintf("\n[ft.start] trigger [%d] watch dir [%s] error %s.", ft.Id, ft.Dir, err.Error())
+		return errors.New(e)
+	}
+	g.L.Infof("[ft.start] trigger [%d] is watching dir [%s] pattern [%s].\n", ft.Id, ft.Dir, ft.Pattern)
+
+	go ft.listen(events, ft.fire)
+
+	//停止期间到达的文件按到达处理，其中仍在写入的文件由listen等待修改时间稳定后再触发
+	files, err := ioutil.ReadDir(ft.Dir)
+	if err != nil {
+		g.L.Warnf("[ft.start] trigger [%d] read dir [%s] error %s.\n", ft.Id, ft.Dir, err.Error())
+		return nil
+	}
+	for _, f := range files {
+		if !f.IsDir() {
+			events <- filepath.Join(ft.Dir, f.Name())
+		}
+	}
+
+	return nil
+} // }}}
+
+//listen接收目录中文件变化的通知，文件在Debounce秒内不再变化后调用fire触发。
+//到期时文件的修改时间距今不足Debounce秒的继续等待。
+func (ft *FileTrigger) listen(events chan string, onFire func(path string) error) { // {{{
+	pending := make(map[string]*time.Timer)
+	fire := make(chan string)
+	debounce := time.Duration(ft.Debounce) * time.Second
+
+	for {
+		select {
+		case path, ok := <-events:
+			if !ok {
+				g.L.Warnf("[ft.listen] trigger [%d] stop watching dir [%s].\n", ft.Id, ft.Dir)
+				return
+			}
+			if matched, _ := filepath.Match(ft.Pattern, filepath.Base(path)); !matched {
+				continue
+			}
+			if t, ok := pending[path]; ok {
+				t.Reset(debounce)
+				continue
+			}
+			p := path
+			pending[path] = time.AfterFunc(debounce, func() { fire <- p })
+		case path := <-fire:
+			delete(pending, path)
+			if fi, err := os.Stat(path); err == nil {
+				if wait := debounce - time.Since(fi.ModTime()); wait > 0 {
+					if wait > debounce {
+						wait = debounce
+					}
+					p := path
+					pending[path] = time.AfterFunc(wait, func() { fire <- p })
+					continue
+				}
+			}
+			if err := onFire(path); err != nil {
+				g.L.Warnf("[ft.listen] %s\n", err.Error())
+			}
+		}
+	}
+} // }}}
+
+//fire为到达的文件启动调度的执行批次，文件路径作为批次参数file传入，
+//任务可以在命令模板中以.conf.file、在环境变量SCD_CONF中读取。已处理的文件不再触发。
+func (ft *FileTrigger) fire(path string) error { // {{{
+	fi, err := os.Stat(path)
+	if err != nil {
+		e := fmt.Sprintf("\n[ft.fire] trigger [%d] stat file [%s] error %s.", ft.Id, path, err.Error())
+		return errors.New(e)
+	}
+	//datetime只保存到秒
+	size, mtime := fi.Size(), fi.ModTime().Truncate(time.Second)
+
+	done, err := ft.isProcessed(path, size, mtime)
+	if err != nil {
+		e := fmt.Sprintf("\n[ft.fire] %s", err.Error())
+		return errors.New(e)
+	}
+	if done {
+		return nil
+	}
+
+	s := g.Schedules.GetScheduleById(ft.ScheduleId)
+	if s == nil {
+		e := fmt.Sprintf("\n[ft.fire] trigger [%d] not found schedule [%d].", ft.Id, ft.ScheduleId)
+		return errors.New(e)
+	}
+	var ids map[int64]bool
+	if ft.TaskId != 0 {
+		if s.GetTaskById(ft.TaskId) == nil {
+			e := fmt.Sprintf("\n[ft.fire] trigger [%d] not found task [%d] in schedule [%d %s].", ft.Id, ft.TaskId, s.Id, s.Name)
+			return errors.New(e)
+		}
+		ids = map[int64]bool{ft.TaskId: true}
+	}
+
+	conf := map[string]interface{}{"file": path, "trigger_id": ft.Id}
+	es, err := s.trigger(time.Now(), 8, ids, conf, nil)
+	if err != nil {
+		e := fmt.Sprintf("\n[ft.fire] %s", err.Error())
+		return errors.New(e)
+	}
+	//先记录文件再执行，保证每个文件只触发一次
+	if err = ft.addProcessed(path, size, mtime, es.batchId); err != nil {
+		g.Schedules.RemoveExecSchedule(es.batchId)
+		e := fmt.Sprintf("\n[ft.fire] %s", err.Error())
+		return errors.New(e)
+	}
+	g.L.Infof("[ft.fire] trigger [%d] file [%s] start schedule [%d %s] batchId=[%s].\n", ft.Id, path, s.Id, s.Name, es.batchId)
+
+	go es.Run()
+	return nil
+} // }}}
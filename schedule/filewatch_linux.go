@@ -0,0 +1,58 @@
+//go:build linux
+// +build linux
+
+package schedule
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+//watchDir使用inotify监听目录dir，目录中的文件写入后关闭或移入时，
+//将文件路径写入events。只在文件关闭后通知，写入中的文件不会触发。监听出错时关闭events。
+func watchDir(dir string, events chan<- string) error { // {{{
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO)
+	if _, err = syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	go func() {
+		defer syscall.Close(fd)
+		defer close(events)
+
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := syscall.Read(fd, buf)
+			if err == syscall.EINTR {
+				continue
+			}
+			if err != nil || n <= 0 {
+				g.L.Warnf("[watchDir] read inotify events of dir [%s] error %v.\n", dir, err)
+				return
+			}
+
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				start := off + syscall.SizeofInotifyEvent
+				end := start + int(ev.Len)
+				if end > n {
+					break
+				}
+				name := strings.TrimRight(string(buf[start:end]), "\x00")
+				if ev.Mask&syscall.IN_ISDIR == 0 && name != "" {
+					events <- filepath.Join(dir, name)
+				}
+				off = end
+			}
+		}
+	}()
+
+	return nil
+} // }}}
@@ -0,0 +1,112 @@
+//go:build linux
+// +build linux
+
+package schedule
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+//watchFired监听目录dir，返回记录触发的文件及触发时文件大小的函数
+func watchFired(t *testing.T, ft *FileTrigger) func() map[string]int64 { // {{{
+	var lock sync.Mutex
+	fired := make(map[string]int64)
+	events := make(chan string, 64)
+	if err := watchDir(ft.Dir, events); err != nil {
+		t.Fatal(err)
+	}
+	go ft.listen(events, func(path string) error {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		fired[path] = fi.Size()
+		lock.Unlock()
+		return nil
+	})
+
+	return func() map[string]int64 {
+		lock.Lock()
+		defer lock.Unlock()
+		m := make(map[string]int64)
+		for k, v := range fired {
+			m[k] = v
+		}
+		return m
+	}
+} // }}}
+
+//写入时间超过Debounce的文件在关闭后才触发，触发时文件已完整
+func TestFileTriggerDebounce(t *testing.T) { // {{{
+	useTestDB(t)
+	dir := t.TempDir()
+	ft := &FileTrigger{Id: 1, Dir: dir, Pattern: "*.csv", Debounce: 1}
+	fired := watchFired(t, ft)
+
+	path := filepath.Join(dir, "data.csv")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		f.WriteString("0123456789")
+		time.Sleep(500 * time.Millisecond)
+	}
+	if n := len(fired()); n != 0 {
+		t.Fatalf("%d files fired while still being written", n)
+	}
+	f.Close()
+
+	//不匹配的文件不触发
+	ioutil.WriteFile(filepath.Join(dir, "data.tmp"), []byte("x"), 0644)
+
+	time.Sleep(500 * time.Millisecond)
+	if n := len(fired()); n != 0 {
+		t.Fatalf("%d files fired before debounce", n)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	got := fired()
+	if len(got) != 1 || got[path] != 40 {
+		t.Fatalf("fired %v, want %s with 40 bytes", got, path)
+	}
+} // }}}
+
+//启动时目录中刚修改过的文件等修改时间稳定后再触发
+func TestFileTriggerStartupQuiet(t *testing.T) { // {{{
+	useTestDB(t)
+	dir := t.TempDir()
+	ft := &FileTrigger{Id: 1, Dir: dir, Pattern: "*", Debounce: 1}
+	events := make(chan string, 1)
+	var lock sync.Mutex
+	var at time.Time
+	go ft.listen(events, func(path string) error {
+		lock.Lock()
+		at = time.Now()
+		lock.Unlock()
+		return nil
+	})
+
+	path := filepath.Join(dir, "a")
+	ioutil.WriteFile(path, []byte("x"), 0644)
+	events <- path
+	time.Sleep(700 * time.Millisecond)
+	//计时器到期前再次修改，修改时间之后还需等待Debounce
+	ioutil.WriteFile(path, []byte("xy"), 0644)
+	modified := time.Now()
+
+	time.Sleep(2 * time.Second)
+	lock.Lock()
+	defer lock.Unlock()
+	if at.IsZero() {
+		t.Fatalf("file not fired")
+	}
+	if d := at.Sub(modified); d < 900*time.Millisecond {
+		t.Errorf("file fired %s after last modification, want at least debounce", d)
+	}
+} // }}}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package schedule
+
+import (
+	"errors"
+	"runtime"
+)
+
+//watchDir文件触发器依赖inotify，只支持linux
+func watchDir(dir string, events chan<- string) error { // {{{
+	return errors.New("file trigger is not supported on " + runtime.GOOS)
+} // }}}
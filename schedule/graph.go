@@ -0,0 +1,177 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//AddRelTask为调度中的任务id增加依赖任务relid，检查通过后持久化，
+//并通知调度刷新任务信息。成功返回任务。
+func (s *Schedule) AddRelTask(id, relid int64) (*Task, error) { // {{{
+	if err := s.CheckRelTask(id, relid); err != nil {
+		e := fmt.Sprintf("\n[s.AddRelTask] %s", err.Error())
+		return nil, errors.New(e)
+	}
+
+	t := s.GetTaskById(id)
+	if err := t.AddRelTask(g.Schedules.getTaskById(relid)); err != nil {
+		e := fmt.Sprintf("\n[s.AddRelTask] %s", err.Error())
+		return nil, errors.New(e)
+	}
+	s.UpdateTask(t)
+
+	return t, nil
+} // }}}
+
+//CheckRelTask检查任务id依赖任务relid是否合法。
+//依赖自身、依赖的任务不存在、依赖关系已存在以及形成循环依赖时返回error信息，
+//循环依赖时错误信息中包含循环的路径。id为0时表示尚未保存的新任务。
+//relid可以是其它调度中的任务，执行时按周期等待其完成，循环检查时同样沿其依赖关系查找。
+func (s *Schedule) CheckRelTask(id, relid int64) error { // {{{
+	if id != 0 && id == relid {
+		e := fmt.Sprintf("\n[s.CheckRelTask] task [%d] can not depend on itself.", id)
+		return errors.New(e)
+	}
+
+	var t *Task
+	if id != 0 {
+		if t = s.GetTaskById(id); t == nil {
+			e := fmt.Sprintf("\n[s.CheckRelTask] not found task [%d] in schedule [%d %s].", id, s.Id, s.Name)
+			return errors.New(e)
+		}
+	}
+
+	if g.Schedules.getTaskById(relid) == nil {
+		e := fmt.Sprintf("\n[s.CheckRelTask] not found reltask [%d].", relid)
+		return errors.New(e)
+	}
+
+	if t == nil {
+		return nil
+	}
+	for _, rid := range t.RelTasksId {
+		if rid == relid {
+			e := fmt.Sprintf("\n[s.CheckRelTask] task [%d] already depends on task [%d].", id, relid)
+			return errors.New(e)
+		}
+	}
+
+	//relid沿依赖关系能到达id时，增加依赖后形成循环
+	if path := s.relPath(relid, id, make(map[int64]bool)); path != nil {
+		path = append([]int64{id}, path...)
+		e := fmt.Sprintf("\n[s.CheckRelTask] dependency cycle %s.", s.formatPath(path))
+		return errors.New(e)
+	}
+
+	return nil
+} // }}}
+
+//checkGraph检查调度中全部任务的依赖关系，存在依赖自身、依赖的任务不存在或循环依赖时返回error信息。
+//依赖其它调度中的任务时沿该任务的依赖关系继续检查，跨调度的循环会使双方一直等待。
+func (s *Schedule) checkGraph() error { // {{{
+	//深度优先遍历，state 1.遍历中 2.已完成
+	state := make(map[int64]int8)
+	stack := make([]int64, 0)
+	var missing error
+	var visit func(t *Task) []int64
+	visit = func(t *Task) []int64 {
+		state[t.Id] = 1
+		stack = append(stack, t.Id)
+		for _, rid := range t.RelTasksId {
+			switch state[rid] {
+			case 1:
+				//找到循环，返回从rid开始的路径
+				for i, id := range stack {
+					if id == rid {
+						return append(append([]int64{}, stack[i:]...), rid)
+					}
+				}
+			case 0:
+				rt := s.findTask(rid)
+				if rt == nil {
+					e := fmt.Sprintf("\n[s.checkGraph] schedule [%d %s] task [%d %s] depends on task [%d] not found.", s.Id, s.Name, t.Id, t.Name, rid)
+					missing = errors.New(e)
+					return nil
+				}
+				if cycle := visit(rt); cycle != nil || missing != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[t.Id] = 2
+		return nil
+	}
+
+	for _, t := range s.Tasks {
+		if state[t.Id] != 0 {
+			continue
+		}
+		if cycle := visit(t); cycle != nil {
+			e := fmt.Sprintf("\n[s.checkGraph] schedule [%d %s] dependency cycle %s.", s.Id, s.Name, s.formatPath(cycle))
+			return errors.New(e)
+		}
+		if missing != nil {
+			return missing
+		}
+	}
+
+	return nil
+} // }}}
+
+//relPath沿依赖关系查找从任务from到任务to的路径，找不到返回nil
+func (s *Schedule) relPath(from, to int64, visited map[int64]bool) []int64 { // {{{
+	if from == to {
+		return []int64{to}
+	}
+	if visited[from] {
+		return nil
+	}
+	visited[from] = true
+
+	t := s.findTask(from)
+	if t == nil {
+		return nil
+	}
+	for _, rid := range t.RelTasksId {
+		if path := s.relPath(rid, to, visited); path != nil {
+			return append([]int64{from}, path...)
+		}
+	}
+	return nil
+} // }}}
+
+//formatPath将任务id路径格式化为 name(id) -> name(id) 的形式
+func (s *Schedule) formatPath(path []int64) string { // {{{
+	names := make([]string, 0, len(path))
+	for _, id := range path {
+		if t := s.findTask(id); t != nil {
+			names = append(names, fmt.Sprintf("%s(%d)", t.Name, id))
+		} else {
+			names = append(names, fmt.Sprintf("(%d)", id))
+		}
+	}
+	return strings.Join(names, " -> ")
+} // }}}
+
+//findTask查找指定id的任务，先在本调度中查找，找不到时在全部调度中查找
+func (s *Schedule) findTask(id int64) *Task { // {{{
+	if t := s.GetTaskById(id); t != nil {
+		return t
+	}
+	if g == nil || g.Schedules == nil {
+		return nil
+	}
+	return g.Schedules.getTaskById(id)
+} // }}}
+
+//getTaskById在全部调度中查找指定id的任务，找不到返回nil
+func (sl *ScheduleManager) getTaskById(id int64) *Task { // {{{
+	for _, s := range sl.ScheduleList {
+		if t := s.GetTaskById(id); t != nil {
+			return t
+		}
+	}
+	return nil
+} // }}}
@@ -0,0 +1,95 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+)
+
+//newGraphSchedule按id及依赖关系构建测试用的调度，rels中key为任务id，value为依赖的任务id
+func newGraphSchedule(id int64, rels map[int64][]int64) *Schedule { // {{{
+	s := &Schedule{Id: id, Name: "s"}
+	for tid, rids := range rels {
+		s.Tasks = append(s.Tasks, &Task{Id: tid, Name: "t", RelTasksId: rids})
+	}
+	return s
+} // }}}
+
+func TestCheckGraph(t *testing.T) { // {{{
+	cases := []struct {
+		name string
+		rels map[int64][]int64
+		want string //错误信息中应包含的内容，为空时不应返回错误
+	}{
+		{"empty", map[int64][]int64{}, ""},
+		{"chain", map[int64][]int64{1: nil, 2: {1}, 3: {2}}, ""},
+		{"diamond", map[int64][]int64{1: nil, 2: {1}, 3: {1}, 4: {2, 3}}, ""},
+		{"self", map[int64][]int64{1: {1}}, "dependency cycle"},
+		{"two", map[int64][]int64{1: {2}, 2: {1}}, "dependency cycle"},
+		{"three", map[int64][]int64{1: {3}, 2: {1}, 3: {2}, 4: {1}}, "dependency cycle"},
+		{"missing", map[int64][]int64{1: {99}}, "not found"},
+		{"missing upstream", map[int64][]int64{1: nil, 2: {1, 99}, 3: {2}}, "not found"},
+	}
+
+	old := g
+	defer func() { g = old }()
+	g = &GlobalConfigStruct{Schedules: &ScheduleManager{}}
+
+	for _, c := range cases {
+		s := newGraphSchedule(1, c.rels)
+		g.Schedules.ScheduleList = []*Schedule{s}
+		err := s.checkGraph()
+		if c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)) {
+			t.Errorf("%s: checkGraph() = %v, want %s", c.name, err, c.want)
+		}
+		if c.want == "" && err != nil {
+			t.Errorf("%s: checkGraph() = %v, want nil", c.name, err)
+		}
+	}
+} // }}}
+
+//两个调度中的任务相互依赖时，双方都应检查出循环
+func TestCheckGraphCrossSchedule(t *testing.T) { // {{{
+	old := g
+	defer func() { g = old }()
+
+	s1 := newGraphSchedule(1, map[int64][]int64{1: {20}, 2: {1}})
+	s2 := newGraphSchedule(2, map[int64][]int64{20: {2}})
+	g = &GlobalConfigStruct{Schedules: &ScheduleManager{ScheduleList: []*Schedule{s1, s2}}}
+
+	for _, s := range []*Schedule{s1, s2} {
+		if err := s.checkGraph(); err == nil {
+			t.Errorf("schedule [%d] checkGraph() = nil, want cross schedule cycle", s.Id)
+		}
+	}
+
+	//依赖其它调度中的任务但不形成循环
+	s2.Tasks[0].RelTasksId = nil
+	for _, s := range []*Schedule{s1, s2} {
+		if err := s.checkGraph(); err != nil {
+			t.Errorf("schedule [%d] checkGraph() = %v, want nil", s.Id, err)
+		}
+	}
+	if err := s2.CheckRelTask(20, 2); err == nil {
+		t.Errorf("CheckRelTask(20, 2) = nil, want cross schedule cycle")
+	}
+} // }}}
+
+//依赖的任务在本调度中时放入RelTasks，在其它调度中时放入ExtTasksId，找不到的不设置依赖
+func TestResolveRelTasks(t *testing.T) { // {{{
+	useTestDB(t)
+	s1 := newGraphSchedule(1, map[int64][]int64{1: nil, 2: {1, 20, 99}})
+	s2 := newGraphSchedule(2, map[int64][]int64{20: nil})
+	g.Schedules.ScheduleList = []*Schedule{s1, s2}
+
+	s1.resolveRelTasks()
+	rt := s1.GetTaskById(2)
+	if rt.RelTaskCnt != 1 || rt.RelTasks["1"] == nil {
+		t.Errorf("RelTasks = %v, want task 1 only", rt.RelTasks)
+	}
+	if len(rt.ExtTasksId) != 1 || rt.ExtTasksId[0] != 20 {
+		t.Errorf("ExtTasksId = %v, want [20]", rt.ExtTasksId)
+	}
+	if err := s1.checkGraph(); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("checkGraph() = %v, want reltask 99 not found", err)
+	}
+} // }}}
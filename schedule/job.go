@@ -3,17 +3,18 @@ package schedule
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 )
 
 //作业信息结构
 type Job struct { // {{{
-	Id           int64  //作业ID
-	ScheduleId   int64  //调度ID
-	ScheduleCyc  string //调度周期
-	ReInit       int    //
-	ExecType     int8   //
-	Disabled     int8
+	Id           int64            //作业ID
+	ScheduleId   int64            //调度ID
+	ScheduleCyc  string           //调度周期
+	ReInit       int              //
+	ExecType     int8             //作业中有任务失败时的处理方式 0.阻塞下级作业 1.继续执行下级作业
+	Disabled     int8             //禁用的作业不执行，执行日志中记录为忽略
 	Name         string           //作业名称
 	Desc         string           //作业说明
 	PreJobId     int64            //上级作业ID
@@ -63,7 +64,7 @@ func (j *Job) InitTasksForJob(s *Schedule) error { // {{{
 			e := fmt.Sprintf("\n[t.InitTaskForJob] %s.", err.Error())
 			return errors.New(e)
 		}
-		j.Tasks[string(taskid)] = task
+		j.Tasks[strconv.FormatInt(taskid, 10)] = task
 
 		task.ScheduleCyc = j.ScheduleCyc
 		j.TaskCnt++
@@ -77,14 +78,20 @@ func (j *Job) InitTasksForJob(s *Schedule) error { // {{{
 //它会根据参数查找本Job下符合的Task，找到后更新信息
 //并调用Task的add方法进行持久化操作。
 func (j *Job) UpdateTask(task *Task) (err error) { // {{{
-	t, ok := j.Tasks[string(task.Id)]
+	t, ok := j.Tasks[strconv.FormatInt(task.Id, 10)]
 	if !ok {
 		e := fmt.Sprintf("\n[j.UpdateTask] update error. not found task by id %d", task.Id)
 		return errors.New(e)
 	}
 	t.Name, t.Desc, t.Address = task.Name, task.Desc, task.Address
-	t.TaskType, t.TaskCyc, t.StartSecond = task.TaskType, task.TaskCyc, task.StartSecond
-	t.Cmd, t.TimeOut = task.Cmd, task.TimeOut
+	t.TaskType, t.TaskCyc, t.StartSecond, t.StartList = task.TaskType, task.TaskCyc, task.StartSecond, task.StartList
+	t.Cmd, t.TimeOut, t.Misfire = task.Cmd, task.TimeOut, task.Misfire
+	t.Concurrent, t.Overlap, t.RelWaitTime = task.Concurrent, task.Overlap, task.RelWaitTime
+	t.TriggerRule, t.JoinWindow, t.ExecMode = task.TriggerRule, task.JoinWindow, task.ExecMode
+	t.Executor = task.Executor
+	t.Retry, t.RetryDelay, t.Backoff = task.Retry, task.RetryDelay, task.Backoff
+	t.MaxDelay, t.RetryOn = task.MaxDelay, task.RetryOn
+	t.SlaStart, t.SlaFinish, t.SlaDuration = task.SlaStart, task.SlaFinish, task.SlaDuration
 	t.Attr, t.ModifyUserId, t.ModifyTime = task.Attr, task.ModifyUserId, NowTimePtr()
 
 	return nil
@@ -92,7 +99,7 @@ func (j *Job) UpdateTask(task *Task) (err error) { // {{{
 
 //删除作业任务映射关系至元数据库
 func (j *Job) DeleteTask(taskid int64) (err error) { // {{{
-	delete(j.Tasks, string(taskid))
+	delete(j.Tasks, strconv.FormatInt(taskid, 10))
 	j.TaskCnt--
 
 	return nil
@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	//汇合启动任务的依赖任务完成时间，key为汇合启动的任务ID及其依赖的任务ID
+	joins    = make(map[int64]map[int64]time.Time)
+	joinLock sync.Mutex
+)
+
+//joinDone在任务执行结束后调用，记录依赖该任务汇合启动的任务中该任务的完成时间。
+//只记录自动定时调度及汇合执行批次中执行成功的任务，动态生成的子任务不记录。
+//汇合启动的任务依赖的同一调度中的任务全部在JoinWindow时间窗口内完成后，
+//以执行类型6启动一个只包含该任务及跟随其启动的依赖任务的批次，批次的启动时间与本批次相同。
+//本批次中已包含的汇合任务随本批次执行，不再单独启动。
+func (es *ExecSchedule) joinDone(et *ExecTask) { // {{{
+	if (es.execType != 1 && es.execType != 6) || et.state != 3 || et.id != et.task.Id {
+		return
+	}
+
+	s, t := es.schedule, et.task
+	now := time.Now()
+	idkey := strconv.FormatInt(t.Id, 10)
+
+	joinLock.Lock()
+	ready := make([]*Task, 0)
+	for _, jt := range s.Tasks {
+		if jt.TaskType != 2 || jt.JoinWindow <= 0 || jt.Disabled != 0 {
+			continue
+		}
+		if _, ok := jt.RelTasks[idkey]; !ok {
+			continue
+		}
+		if _, ok := es.execTasks[jt.Id]; ok {
+			continue
+		}
+
+		done, ok := joins[jt.Id]
+		if !ok {
+			done = make(map[int64]time.Time)
+			joins[jt.Id] = done
+		}
+		done[t.Id] = now
+
+		//移除超出时间窗口的完成记录
+		window := time.Duration(jt.JoinWindow) * time.Second
+		for id, tm := range done {
+			if now.Sub(tm) > window {
+				delete(done, id)
+			}
+		}
+
+		all := true
+		for _, rt := range jt.RelTasks {
+			if _, ok := done[rt.Id]; !ok {
+				all = false
+				break
+			}
+		}
+		if all {
+			delete(joins, jt.Id)
+			ready = append(ready, jt)
+		}
+	}
+	joinLock.Unlock()
+
+	for _, jt := range ready {
+		go s.runJoin(jt, es.runTime)
+	}
+} // }}}
+
+//runJoin启动汇合任务jt的执行批次，跟随jt启动的依赖任务一同执行
+func (s *Schedule) runJoin(jt *Task, runTime time.Time) { // {{{
+	ids := map[int64]bool{jt.Id: true}
+	s.addDependTasks(ids)
+
+	g.L.Infof("[s.runJoin] schedule [%d %s] task [%d %s] reltasks are all done, run %d tasks.\n",
+		s.Id, s.Name, jt.Id, jt.Name, len(ids))
+	es := newExecSchedule(s, runTime, 6, ids)
+	if err := s.initExecSchedule(es); err != nil {
+		g.L.Warnf("[s.runJoin] %s\n", err.Error())
+		return
+	}
+	es.Run()
+} // }}}
@@ -0,0 +1,50 @@
+package schedule
+
+import (
+	"testing"
+)
+
+//只有自动定时调度及汇合执行批次中执行成功的任务计入汇合
+func TestJoinDone(t *testing.T) { // {{{
+	up1 := &Task{Id: 1, Name: "up1"}
+	up2 := &Task{Id: 2, Name: "up2"}
+	jt := &Task{Id: 3, Name: "join", TaskType: 2, JoinWindow: 60,
+		RelTasks: map[string]*Task{"1": up1, "2": up2}, RelTasksId: []int64{1, 2}}
+	s := &Schedule{Id: 1, Name: "s", Tasks: []*Task{up1, up2, jt}}
+	ej := &ExecJob{batchJobId: "b.1", batchId: "b", execTasks: make(map[int64]*ExecTask)}
+
+	cases := []struct {
+		name     string
+		execType int8
+		state    int8
+		inBatch  bool
+		recorded bool
+	}{
+		{"auto success", 1, 3, false, true},
+		{"join success", 6, 3, false, true},
+		{"auto skipped", 1, 5, false, false},
+		{"manual success", 2, 3, false, false},
+		{"webhook success", 7, 3, false, false},
+		{"join task in batch", 1, 3, true, false},
+	}
+	for _, c := range cases {
+		joinLock.Lock()
+		delete(joins, jt.Id)
+		joinLock.Unlock()
+
+		es := &ExecSchedule{schedule: s, execType: c.execType, execTasks: make(map[int64]*ExecTask)}
+		if c.inBatch {
+			es.execTasks[jt.Id] = ExecTaskWarper(ej, jt)
+		}
+		et := ExecTaskWarper(ej, up1)
+		et.state = c.state
+		es.joinDone(et)
+
+		joinLock.Lock()
+		_, ok := joins[jt.Id][up1.Id]
+		joinLock.Unlock()
+		if ok != c.recorded {
+			t.Errorf("%s: recorded = %v, want %v", c.name, ok, c.recorded)
+		}
+	}
+} // }}}
@@ -0,0 +1,117 @@
+package schedule
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//命令模板中可以使用的日期函数
+var cmdFuncs = template.FuncMap{ // {{{
+	"format":          formatDate,
+	"addDays":         func(n int, t time.Time) time.Time { return t.AddDate(0, 0, n) },
+	"addMonths":       func(n int, t time.Time) time.Time { return t.AddDate(0, n, 0) },
+	"addHours":        func(n int, t time.Time) time.Time { return t.Add(time.Duration(n) * time.Hour) },
+	"yesterday":       func(t time.Time) time.Time { return TruncDate("d", t).AddDate(0, 0, -1) },
+	"tomorrow":        func(t time.Time) time.Time { return TruncDate("d", t).AddDate(0, 0, 1) },
+	"firstDayOfMonth": func(t time.Time) time.Time { return TruncDate("m", t) },
+	"lastDayOfMonth":  func(t time.Time) time.Time { return TruncDate("m", t).AddDate(0, 1, -1) },
+	"firstDayOfWeek":  func(t time.Time) time.Time { return TruncDate("w", t) },
+	"truncate":        TruncDate,
+} // }}}
+
+//renderCmd使用text/template渲染任务的命令。渲染需在任务属性中设置cmd_template为1，
+//未设置或命令中不含模板标记时原样返回，避免命令中本身带有{{的内容被当作模板，
+//如 docker ps --format '{{.ID}}'。
+//模板中可以使用的变量：
+//  .runTime      批次对应的启动时间
+//  .periodStart  按任务的调度周期对启动时间取整得到的周期开始时间
+//  .periodEnd    周期结束时间，即下一周期的开始时间
+//  .batchId      批次ID
+//  .batchTaskId  任务批次ID
+//  .taskName     任务名称
+//  .scheduleName 调度名称
+//  .result       依赖的任务发布的结果，如 {{.result.rows}}
+//  .conf         批次的参数，webhook触发时为请求中的json，如 {{.conf.date}}
+//  .results      按依赖任务名称区分的结果，如 {{index .results "load" "rows"}}
+//任务属性Attr中的项同样可以作为变量使用，与上述变量同名时以上述变量为准。
+//日期函数见cmdFuncs，如 {{format "yyyy-MM-dd" (yesterday .runTime)}}
+func (et *ExecTask) renderCmd() (string, error) { // {{{
+	t := et.task
+	if t.Attr["cmd_template"] != "1" || !strings.Contains(t.Cmd, "{{") {
+		return t.Cmd, nil
+	}
+
+	tmpl, err := template.New(t.Name).Funcs(cmdFuncs).Option("missingkey=error").Parse(t.Cmd)
+	if err != nil {
+		e := fmt.Sprintf("\n[et.renderCmd] parse cmd of task [%d %s] error %s.", t.Id, t.Name, err.Error())
+		return "", errors.New(e)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, et.cmdVars()); err != nil {
+		e := fmt.Sprintf("\n[et.renderCmd] render cmd of task [%d %s] error %s.", t.Id, t.Name, err.Error())
+		return "", errors.New(e)
+	}
+
+	return buf.String(), nil
+} // }}}
+
+//cmdVars返回渲染命令时使用的变量
+func (et *ExecTask) cmdVars() map[string]interface{} { // {{{
+	vars := make(map[string]interface{})
+	for k, v := range et.task.Attr {
+		vars[k] = v
+	}
+
+	start, end := et.period()
+	vars["runTime"] = et.execJob.runTime
+	vars["periodStart"] = start
+	vars["periodEnd"] = end
+	vars["batchId"] = et.batchId
+	vars["batchTaskId"] = et.batchTaskId
+	vars["taskName"] = et.task.Name
+	vars["conf"] = et.execJob.conf
+	vars["result"] = et.relResults()
+	results := make(map[string]map[string]string)
+	for name, res := range et.extResults {
+		results[name] = res
+	}
+	for _, rt := range et.relExecTasks {
+		if rt.results != nil {
+			results[rt.task.Name] = rt.results
+		}
+	}
+	vars["results"] = results
+	vars["scheduleName"] = ""
+	if s := g.Schedules.GetScheduleById(et.execJob.job.ScheduleId); s != nil {
+		vars["scheduleName"] = s.Name
+	}
+
+	return vars
+} // }}}
+
+//period返回批次启动时间按任务的周期取整得到的周期开始及结束时间，
+//任务未设置周期时按所属调度的周期，均未设置时均为批次启动时间。
+func (et *ExecTask) period() (start, end time.Time) { // {{{
+	runTime := et.execJob.runTime
+	cyc := et.task.TaskCyc
+	if cyc == "" {
+		cyc = et.task.ScheduleCyc
+	}
+	if cyc == "" {
+		return runTime, runTime
+	}
+	start = TruncDate(cyc, runTime)
+	return start, AddCycle(cyc, start, 1)
+} // }}}
+
+//formatDate按yyyy、MM、dd、HH、mm、ss格式化时间，
+//格式中不含这些标记时按go的时间格式处理。
+func formatDate(layout string, t time.Time) string { // {{{
+	r := strings.NewReplacer("yyyy", "2006", "MM", "01", "dd", "02", "HH", "15", "mm", "04", "ss", "05")
+	return t.Format(r.Replace(layout))
+} // }}}
@@ -0,0 +1,66 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderCmd(t *testing.T) { // {{{
+	useTestDB(t)
+
+	runTime := time.Date(2024, 3, 5, 10, 30, 0, 0, time.Local)
+	ej := &ExecJob{batchJobId: "b.1", batchId: "b", runTime: runTime, job: &Job{ScheduleId: 1},
+		execTasks: make(map[int64]*ExecTask)}
+	cases := []struct {
+		name string
+		cmd  string
+		attr map[string]string
+		want string
+		err  bool
+	}{
+		{"plain", "echo hi", nil, "echo hi", false},
+		{"not flagged", "docker ps --format '{{.ID}}'", nil, "docker ps --format '{{.ID}}'", false},
+		{"not flagged awk", `jq '{{.a}}' x | awk '{print $1}'`, map[string]string{"cmd_template": "0"},
+			`jq '{{.a}}' x | awk '{print $1}'`, false},
+		{"flagged", `load {{format "yyyyMMdd" .periodStart}} {{.batchId}}`,
+			map[string]string{"cmd_template": "1"}, "load 20240305 b", false},
+		{"attr var", "echo {{.db}}", map[string]string{"cmd_template": "1", "db": "dw"}, "echo dw", false},
+		{"yesterday", `echo {{format "yyyy-MM-dd" (yesterday .runTime)}}`,
+			map[string]string{"cmd_template": "1"}, "echo 2024-03-04", false},
+		{"missing key", "echo {{.nope}}", map[string]string{"cmd_template": "1"}, "", true},
+		{"bad template", "echo {{.runTime", map[string]string{"cmd_template": "1"}, "", true},
+	}
+	for _, c := range cases {
+		task := &Task{Id: 1, Name: c.name, Cmd: c.cmd, Attr: c.attr, ScheduleCyc: "d"}
+		et := ExecTaskWarper(ej, task)
+		got, err := et.renderCmd()
+		if (err != nil) != c.err {
+			t.Errorf("%s: renderCmd() error = %v, want error %v", c.name, err, c.err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: renderCmd() = %q, want %q", c.name, got, c.want)
+		}
+	}
+} // }}}
+
+//任务的周期优先，未设置时按所属调度的周期
+func TestTaskPeriod(t *testing.T) { // {{{
+	runTime := time.Date(2024, 3, 5, 10, 30, 0, 0, time.Local)
+	ej := &ExecJob{batchJobId: "b.1", batchId: "b", runTime: runTime}
+	cases := []struct {
+		taskCyc, scheduleCyc string
+		start, end           time.Time
+	}{
+		{"m", "d", time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local)},
+		{"", "d", time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local), time.Date(2024, 3, 6, 0, 0, 0, 0, time.Local)},
+		{"", "", runTime, runTime},
+	}
+	for _, c := range cases {
+		et := ExecTaskWarper(ej, &Task{Id: 1, TaskCyc: c.taskCyc, ScheduleCyc: c.scheduleCyc})
+		if start, end := et.period(); !start.Equal(c.start) || !end.Equal(c.end) {
+			t.Errorf("period() taskCyc=%q scheduleCyc=%q = %s, %s, want %s, %s",
+				c.taskCyc, c.scheduleCyc, start, end, c.start, c.end)
+		}
+	}
+} // }}}
@@ -0,0 +1,84 @@
+package schedule
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//标准输出中发布结果的行标记，格式为 ##result key=value
+	resultMark = "##result "
+)
+
+//parseResults从任务的标准输出中解析发布的结果，同名的结果以最后一次为准
+func parseResults(stdout string) map[string]string { // {{{
+	results := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(stdout))
+	scanner.Buffer(make([]byte, 64*1024), len(stdout)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, resultMark) {
+			continue
+		}
+		kv := strings.SplitN(strings.TrimSpace(line[len(resultMark):]), "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			continue
+		}
+		results[strings.TrimSpace(kv[0])] = kv[1]
+	}
+
+	return results
+} // }}}
+
+//relResults合并依赖的任务发布的结果，多个依赖任务发布同名结果时取值不确定。
+//本批次中执行的依赖任务的结果优先于从结果表加载的结果。
+func (et *ExecTask) relResults() map[string]string { // {{{
+	results := make(map[string]string)
+	for _, res := range et.extResults {
+		for k, v := range res {
+			results[k] = v
+		}
+	}
+	for _, rt := range et.relExecTasks {
+		for k, v := range rt.results {
+			results[k] = v
+		}
+	}
+
+	return results
+} // }}}
+
+//loadRelResults从结果表加载不在本批次中执行的依赖任务发布的结果，只加载一次。
+//包括修复执行时已执行成功的任务、其它调度中的任务以及汇合执行时在之前批次中完成的任务，
+//按本任务的周期匹配这些任务所在批次的启动时间。
+func (et *ExecTask) loadRelResults() { // {{{
+	if et.extResults != nil {
+		return
+	}
+	et.extResults = make(map[string]map[string]string)
+
+	start, end := et.period()
+	if !end.After(start) {
+		end = start.Add(time.Second)
+	}
+	for _, id := range et.task.RelTasksId {
+		if _, ok := et.relExecTasks[id]; ok {
+			continue
+		}
+		results, err := getRelResults(id, start, end)
+		if err != nil {
+			g.L.Warnf("[et.loadRelResults] %s\n", err.Error())
+			continue
+		}
+		if len(results) == 0 {
+			continue
+		}
+		name := strconv.FormatInt(id, 10)
+		if rt := g.Schedules.getTaskById(id); rt != nil {
+			name = rt.Name
+		}
+		et.extResults[name] = results
+	}
+} // }}}
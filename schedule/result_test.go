@@ -0,0 +1,59 @@
+package schedule
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseResults(t *testing.T) { // {{{
+	cases := []struct {
+		stdout string
+		want   map[string]string
+	}{
+		{"", map[string]string{}},
+		{"hello\nworld\n", map[string]string{}},
+		{"##result rows=10\n", map[string]string{"rows": "10"}},
+		{"  ##result  rows=10  \nx\n##result path=/a=b\n", map[string]string{"rows": "10", "path": "/a=b"}},
+		{"##result rows=1\n##result rows=2", map[string]string{"rows": "2"}},
+		{"##result empty=\n", map[string]string{"empty": ""}},
+		{"##result =x\n##result noeq\n##resultrows=1\n", map[string]string{}},
+	}
+	for _, c := range cases {
+		if got := parseResults(c.stdout); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseResults(%q) = %v, want %v", c.stdout, got, c.want)
+		}
+	}
+} // }}}
+
+//不在本批次中的依赖任务从结果表加载结果，本批次中的依赖任务结果优先
+func TestLoadRelResults(t *testing.T) { // {{{
+	useTestDB(t)
+	testRows["scd_task_result"] = &testResult{
+		columns: []string{"result_key", "result_value"},
+		values:  [][]driver.Value{{"rows", "10"}, {"path", "/old"}},
+	}
+
+	extract := &Task{Id: 5, Name: "extract"}
+	load := &Task{Id: 6, Name: "load"}
+	report := &Task{Id: 7, Name: "report", RelTasksId: []int64{5, 6}}
+	g.Schedules.ScheduleList = []*Schedule{{Id: 1, Tasks: []*Task{extract, load, report}}}
+
+	ej := &ExecJob{batchJobId: "b.1", batchId: "b", runTime: time.Now(), job: &Job{ScheduleId: 1}, execTasks: make(map[int64]*ExecTask)}
+	lt := ExecTaskWarper(ej, load)
+	lt.results = map[string]string{"path": "/new"}
+	et := ExecTaskWarper(ej, report)
+	et.relExecTasks[load.Id] = lt
+
+	et.loadRelResults()
+	if got := et.relResults(); !reflect.DeepEqual(got, map[string]string{"rows": "10", "path": "/new"}) {
+		t.Errorf("relResults() = %v", got)
+	}
+	if _, ok := et.extResults["load"]; ok {
+		t.Errorf("results of task in batch loaded from table")
+	}
+	if got := et.cmdVars()["results"].(map[string]map[string]string)["extract"]["rows"]; got != "10" {
+		t.Errorf(`results["extract"]["rows"] = %q, want "10"`, got)
+	}
+} // }}}
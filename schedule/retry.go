@@ -0,0 +1,92 @@
+package schedule
+
+import (
+	"time"
+)
+
+//attempts执行任务，失败时按任务的重试策略最多重试Retry次。
+//第一次重试前等待RetryDelay秒，之后每次等待时间乘以Backoff，最长不超过MaxDelay秒。
+//RetryOn指定需要重试的失败类型，不需要重试的失败直接返回。
+//每次重试单独记录一条执行日志，日志中的attempt为第几次执行，之前失败的日志保留其输出。
+func (et *ExecTask) attempts(task *CmdTask, rl *Reply) (err error) { // {{{
+	t := et.task
+	delay := time.Duration(t.RetryDelay) * time.Second
+	for {
+		task.Attempt = et.attempt
+		err = et.call(task, rl)
+		if err == nil && rl.Err == "" {
+			return nil
+		}
+		if et.aborted || et.attempt > et.Retry || !et.retryable(err, rl) {
+			return err
+		}
+
+		//记录本次失败的执行
+		et.state = 4
+		et.output = rl.Stdout
+		et.stderr = rl.Stderr
+		if err != nil {
+			et.errstr = err.Error()
+		}
+		et.endTime = NowTimePtr()
+		if e := et.Log(); e != nil {
+			g.L.Warnf("[et.attempts] %s\n", e.Error())
+		}
+		g.L.Infoln("task", t.Name, "attempt", et.attempt, "failed, retry in", delay, "batchTaskId[", et.batchTaskId, "]")
+
+		if !et.wait(delay) {
+			return err
+		}
+
+		//开始下一次执行
+		et.attempt++
+		et.state = 1
+		et.startTime = NowTimePtr()
+		et.endTime = nil
+		et.output, et.stderr, et.errstr = "", "", ""
+		if e := et.insertLog(); e != nil {
+			g.L.Warnf("[et.attempts] %s\n", e.Error())
+		}
+		delay = et.nextDelay(delay)
+	}
+} // }}}
+
+//retryable按任务的RetryOn及本次的失败类型判断是否需要重试。
+//程序异常及被新的执行实例中止的不重试。
+func (et *ExecTask) retryable(err error, rl *Reply) bool { // {{{
+	switch et.errType {
+	case 1, 2:
+		return et.task.RetryOn != 2
+	case 3, 4, 5, 7:
+		return et.task.RetryOn != 1
+	case 6, 8:
+		return false
+	}
+	//分发被取消等未分类的失败
+	return et.task.RetryOn == 0
+} // }}}
+
+//nextDelay返回下一次重试前等待的时间
+func (et *ExecTask) nextDelay(delay time.Duration) time.Duration { // {{{
+	t := et.task
+	if t.Backoff > 1 {
+		delay = time.Duration(float64(delay) * t.Backoff)
+	}
+	if max := time.Duration(t.MaxDelay) * time.Second; t.MaxDelay > 0 && delay > max {
+		delay = max
+	}
+	return delay
+} // }}}
+
+//wait等待重试，任务被中止时结束等待并返回false
+func (et *ExecTask) wait(d time.Duration) bool { // {{{
+	for d > 0 && !et.aborted {
+		step := time.Second
+		if d < step {
+			step = d
+		}
+		time.Sleep(step)
+		d -= step
+	}
+	return !et.aborted
+} // }}}
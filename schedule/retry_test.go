@@ -0,0 +1,72 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) { // {{{
+	cases := []struct {
+		errType int8
+		retryOn int8
+		want    bool
+	}{
+		//全部失败都重试，程序异常及被新的执行实例中止的除外
+		{1, 0, true},
+		{2, 0, true},
+		{3, 0, true},
+		{4, 0, true},
+		{5, 0, true},
+		{6, 0, false},
+		{7, 0, true},
+		{8, 0, false},
+		{0, 0, true},
+		//只重试执行模块无法连接及RPC调用错误
+		{1, 1, true},
+		{2, 1, true},
+		{3, 1, false},
+		{4, 1, false},
+		{6, 1, false},
+		{0, 1, false},
+		//只重试命令执行失败
+		{1, 2, false},
+		{2, 2, false},
+		{3, 2, true},
+		{4, 2, true},
+		{5, 2, true},
+		{7, 2, true},
+		{6, 2, false},
+		{8, 2, false},
+		{0, 2, false},
+	}
+	for _, c := range cases {
+		et := &ExecTask{task: &Task{RetryOn: c.retryOn}, errType: c.errType}
+		if got := et.retryable(nil, &Reply{}); got != c.want {
+			t.Errorf("retryable() errType=%d retryOn=%d = %v, want %v", c.errType, c.retryOn, got, c.want)
+		}
+	}
+} // }}}
+
+func TestNextDelay(t *testing.T) { // {{{
+	cases := []struct {
+		backoff  float64
+		maxDelay int64
+		delay    time.Duration
+		want     time.Duration
+	}{
+		{0, 0, 10 * time.Second, 10 * time.Second},
+		{1, 0, 10 * time.Second, 10 * time.Second},
+		{0.5, 0, 10 * time.Second, 10 * time.Second},
+		{2, 0, 10 * time.Second, 20 * time.Second},
+		{1.5, 0, 10 * time.Second, 15 * time.Second},
+		{2, 15, 10 * time.Second, 15 * time.Second},
+		{2, 30, 10 * time.Second, 20 * time.Second},
+		{1, 5, 10 * time.Second, 5 * time.Second},
+	}
+	for _, c := range cases {
+		et := &ExecTask{task: &Task{Backoff: c.backoff, MaxDelay: c.maxDelay}}
+		if got := et.nextDelay(c.delay); got != c.want {
+			t.Errorf("nextDelay(%s) backoff=%v maxDelay=%d = %s, want %s", c.delay, c.backoff, c.maxDelay, got, c.want)
+		}
+	}
+} // }}}
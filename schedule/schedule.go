@@ -8,12 +8,23 @@ import (
 	"fmt"
 	"github.com/Sirupsen/logrus"
 	"sort"
-	//"sync"
+	"sync"
 	"time"
 )
 
 var (
 	g *GlobalConfigStruct
+
+	countLock sync.Mutex //保护调度的执行次数
+)
+
+const (
+	maxMisfireCnt  = 1000 //单个任务补执行的最大次数
+	maxBackfillCnt = 1000 //回填执行的最大周期数
+
+	extTaskPollInterval = 30 * time.Second //检查其它调度中依赖任务执行情况的间隔
+	extTaskWaitTime     = 6 * time.Hour    //任务未设置RelWaitTime时等待其它调度中依赖任务的最长时间
+	slaCheckInterval    = 30 * time.Second //检查批次及任务SLA的间隔
 )
 
 //GlobalConfigStruct结构中定义了程序中的一些配置信息
@@ -24,6 +35,12 @@ type GlobalConfigStruct struct { // {{{
 	ManagerPort string           //管理模块的web服务端口
 	Port        string           //Schedule与Worker模块通信端口
 	Schedules   *ScheduleManager //包含全部Schedule列表的结构
+	AlertUrl    string           //告警地址，为空时告警只写入日志
+
+	MaxRunning          int //全局同时执行的最大任务数量，0不限制
+	MaxRunningPerWorker int //每个执行地址同时执行的最大任务数量，0不限制
+
+	DataConns map[string]*sql.DB //配置中的全部数据库链接，key为链接名称，供sql执行器使用
 } // }}}
 
 type Timer interface {
@@ -38,6 +55,7 @@ func DefaultGlobal() *GlobalConfigStruct { // {{{
 	sc.L = logrus.New()
 	sc.L.Formatter = new(logrus.TextFormatter) // default
 	sc.L.Level = logrus.DebugLevel
+	sc.DataConns = make(map[string]*sql.DB)
 	sc.Port = ":3128"
 	sc.ManagerPort = ":3000"
 	sc.Schedules = &ScheduleManager{Global: sc, ExecScheduleList: make(map[string]*ExecSchedule)}
@@ -47,6 +65,7 @@ func DefaultGlobal() *GlobalConfigStruct { // {{{
 //ScheduleManager通过成员ScheduleList持有全部的Schedule。
 //并提供获取、增加、删除以及启动、停止Schedule的功能。
 type ScheduleManager struct { // {{{
+	lock             sync.Mutex
 	ScheduleList     []*Schedule //全部的调度列表
 	DefaultScd       *Schedule
 	ExecScheduleList map[string]*ExecSchedule //当前执行的调度列表
@@ -72,32 +91,67 @@ func (sl *ScheduleManager) InitScheduleList() { // {{{
 
 //增加一个调度执行结构
 func (sl *ScheduleManager) AddExecSchedule(es *ExecSchedule) { // {{{
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+
 	sl.ExecScheduleList[es.batchId] = es
 	return
 } // }}}
 
 //移除一个调度执行结构
 func (sl *ScheduleManager) RemoveExecSchedule(batchId string) { // {{{
-	/*var lock sync.Mutex
-	lock.Lock()
-	defer lock.Unlock()*/
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
 
 	delete(sl.ExecScheduleList, batchId)
 } // }}}
 
+//isRunning判断调度是否有启动时间为runTime的批次正在执行
+func (sl *ScheduleManager) isRunning(scdId int64, runTime time.Time) bool { // {{{
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+
+	for _, es := range sl.ExecScheduleList {
+		if es.schedule.Id == scdId && es.runTime.Equal(runTime) {
+			return true
+		}
+	}
+	return false
+} // }}}
+
 //开始监听Schedule，遍历列表中的Schedule并启动它的Timer方法。
+//全部调度初始化完成后再检查依赖关系，以便发现跨调度的循环依赖。
 func (sl *ScheduleManager) StartListener() { // {{{
+	inited := make([]*Schedule, 0, len(sl.ScheduleList))
 	for _, scd := range sl.ScheduleList {
 		//从元数据库初始化调度链信息
 		err := scd.InitSchedule()
 		if err != nil {
 			g.L.Warningf("[sl.StartListener] init schedule [%d] error %s.\n", scd.Id, err.Error())
-			return
+			continue
+		}
+		inited = append(inited, scd)
+	}
+
+	//全部调度的任务加载后再设置依赖的任务，以便找到其它调度中的任务
+	for _, scd := range inited {
+		scd.resolveRelTasks()
+	}
+
+	for _, scd := range inited {
+		//依赖关系错误的调度不启动
+		if err := scd.quarantine(); err != nil {
+			g.L.Warningf("[sl.StartListener] %s\n", err.Error())
+			continue
 		}
-		//启动监听，按时启动Schedule
-		go scd.Timer()
+		scd.start()
 	}
 
+	//启动文件触发器
+	sl.StartFileTriggers()
+
+	//启动SLA检查
+	sl.StartSlaChecker()
 }
 
 //启动指定的Schedule，从ScheduleList中获取到指定id的Schedule后，从元数据库获取
@@ -116,9 +170,13 @@ func (sl *ScheduleManager) StartScheduleById(id int64) error { // {{{
 		e := fmt.Sprintf("\n[sl.StartScheduleById] init schedule [%d] error %s.", id, err.Error())
 		return errors.New(e)
 	}
+	s.resolveRelTasks()
+	if err = s.quarantine(); err != nil {
+		e := fmt.Sprintf("\n[sl.StartScheduleById] %s", err.Error())
+		return errors.New(e)
+	}
 
-	//启动监听，按时启动Schedule
-	go s.Timer()
+	s.start()
 
 	return nil
 } // }}}
@@ -178,10 +236,18 @@ func (sl *ScheduleManager) DeleteSchedule(id int64) error { // {{{
 type Schedule struct { // {{{
 	Id             int64     `json:"-"` //调度ID
 	Name           string    `json:"-"` //调度名称
-	Count          int8      `json:"-"` //调度次数
+	Count          int       //调度次数 0.不限次数
+	RunCount       int       //已执行次数，自动定时调度完成的批次数量
+	State          int8      //调度状态 0.正常 1.已完成(执行次数达到调度次数) 2.隔离(依赖关系错误，不启动)
+	running        int       //正在执行中的自动定时调度批次数量
 	Cyc            string    `json:"-"` //调度周期
+	StartList      string    //周期内的启动时间，为空时在周期开始时启动，格式见ParseStartList
 	NextStart      time.Time `json:"-"` //下次启动时间
 	TimeOut        int64     `json:"-"` //最大执行时间
+	Token          string    `json:"-"` //webhook触发调度的令牌，为空时不能通过webhook触发
+	SlaStart       string    //最晚开始时间，见slaDeadline
+	SlaFinish      string    //最晚完成时间，见slaDeadline
+	SlaDuration    int64     //最长执行时间，单位秒，0不限制
 	Jobs           []*Job    `json:"-"` //作业列表
 	Tasks          []*Task   //任务列表
 	isRefresh      chan bool `json:"-"` //是否刷新标志
@@ -192,9 +258,10 @@ type Schedule struct { // {{{
 	CreateTime     time.Time `json:"-"` //创人
 	ModifyUserId   int64     `json:"-"` //修改人
 	ModifyTime     time.Time `json:"-"` //修改时间
+	timer          Timer     //按调度周期计算启动时间
 	updateTaskChan chan *Task
 	doTaskChan     chan *Task
-	delExecSchChan chan string
+	wakeChan       chan bool //执行中的批次结束时唤醒Timer，重新检查调度次数
 } // }}}
 
 //按时启动Schedule，Timer中会根据Schedule的周期以及启动时间计算下次
@@ -205,6 +272,17 @@ func (s *Schedule) Timer() { // {{{
 		g.L.Warnf("[s.Timer] Schedule [%s] Cyc is not set!", s.Name)
 		return
 	}
+	sm, ss, err := ParseStartList(s.StartList)
+	if err != nil {
+		g.L.Warnf("[s.Timer] Schedule [%s] %s", s.Name, err.Error())
+		return
+	}
+	tm, err := Cycle(s.Cyc, sm, ss)
+	if err != nil {
+		g.L.Warnf("[s.Timer] Schedule [%s] %s", s.Name, err.Error())
+		return
+	}
+	s.timer = tm
 	now := time.Now()
 	for _, t := range s.Tasks {
 		t.NextTime(now)
@@ -215,8 +293,8 @@ func (s *Schedule) Timer() { // {{{
 		sort.Sort(byTime(s.Tasks))
 		countDown = time.Duration(0)
 		if len(s.Tasks) == 0 || s.Tasks[0].NextRunTime.IsZero() {
-			countDown, _ = getCountDown(s.Cyc, []int{0}, []time.Duration{time.Duration(0)})
-			s.NextStart = time.Now().Add(countDown)
+			s.NextStart = s.timer.Next(time.Now())
+			countDown = s.NextStart.Sub(time.Now())
 		} else {
 			for _, t := range s.Tasks {
 				if !t.NextRunTime.IsZero() {
@@ -227,17 +305,24 @@ func (s *Schedule) Timer() { // {{{
 			}
 		}
 
+		//执行次数用完后不再定时启动，继续响应任务更新及刷新
+		var timeout <-chan time.Time
+		if s.exhausted() {
+			s.NextStart = time.Time{}
+		} else {
+			timeout = time.After(countDown)
+		}
+
 		select {
-		case <-time.After(countDown):
+		case <-timeout:
 			es := ExecScheduleWarper(s)
-			g.Schedules.AddExecSchedule(es)
 			//构建执行结构链
-			err := es.InitExecSchedule()
+			s.addRunning(1)
+			err := s.initExecSchedule(es)
 			if err != nil {
-				g.L.Warnf("[s.Timer] Init Execschedule [%d %s] error %s.\n", s.Id, s.Name, err.Error())
+				s.addRunning(-1)
+				g.L.Warnf("[s.Timer] %s\n", err.Error())
 			} else {
-				//从元数据库初始化调度链信息
-				g.L.Infof("[s.Timer] schedule [%d %s] is start.\n", s.Id, s.Name)
 				//启动线程执行调度任务
 				go es.Run()
 			}
@@ -251,8 +336,8 @@ func (s *Schedule) Timer() { // {{{
 			t.NextRunTime = now
 		case t := <-s.updateTaskChan:
 			t.Refresh(s)
-		case batchID := <-s.delExecSchChan:
-			g.Schedules.RemoveExecSchedule(batchID)
+		case <-s.wakeChan:
+			now = time.Now()
 		case <-s.isRefresh:
 			g.L.Infof("[s.Timer] schedule [%d %s] is refresh.\n", s.Id, s.Name)
 			return
@@ -261,6 +346,281 @@ func (s *Schedule) Timer() { // {{{
 	return
 } // }}}
 
+//exhausted判断调度次数是否已用完，正在执行中的批次也计入已执行次数。
+//调度次数为0时不限次数。
+func (s *Schedule) exhausted() bool { // {{{
+	countLock.Lock()
+	defer countLock.Unlock()
+
+	return s.Count > 0 && s.RunCount+s.running >= s.Count
+} // }}}
+
+//addRunning调整正在执行中的自动定时调度批次数量，
+//数量减少时唤醒Timer，执行次数未用完时恢复定时启动。
+func (s *Schedule) addRunning(n int) { // {{{
+	countLock.Lock()
+	s.running += n
+	countLock.Unlock()
+
+	if n < 0 {
+		s.wakeup()
+	}
+} // }}}
+
+//wakeup通知Timer重新计算下次启动时间，Timer未在等待时不阻塞。
+func (s *Schedule) wakeup() { // {{{
+	select {
+	case s.wakeChan <- true:
+	default:
+	}
+} // }}}
+
+//runDone在自动定时调度批次完成后增加已执行次数并持久化，
+//执行次数达到调度次数时设置调度状态为已完成。
+//执行中的数量由批次的Run结束时减去，中止的批次不计入已执行次数。
+func (s *Schedule) runDone() error { // {{{
+	countLock.Lock()
+	defer countLock.Unlock()
+
+	s.RunCount++
+	s.setState()
+	if s.State == 1 {
+		g.L.Infof("[s.runDone] schedule [%d %s] run %d times, finished.\n", s.Id, s.Name, s.RunCount)
+	}
+	if s.Id == 0 {
+		return nil
+	}
+
+	return s.updateRunCount()
+} // }}}
+
+//quarantine检查调度的依赖关系，存在错误时将调度状态设置为隔离，
+//发出告警并返回error信息。
+func (s *Schedule) quarantine() error { // {{{
+	err := s.checkGraph()
+	if err == nil {
+		return nil
+	}
+
+	s.State = 2
+	Alert(&AlertEvent{
+		Type:         "quarantine",
+		ScheduleId:   s.Id,
+		ScheduleName: s.Name,
+		Message:      err.Error(),
+	})
+	e := fmt.Sprintf("\n[s.quarantine] schedule [%d %s] is quarantined. %s", s.Id, s.Name, err.Error())
+	return errors.New(e)
+} // }}}
+
+//setState根据调度次数及已执行次数设置调度状态
+func (s *Schedule) setState() { // {{{
+	if s.Count > 0 && s.RunCount >= s.Count {
+		s.State = 1
+	} else {
+		s.State = 0
+	}
+} // }}}
+
+//Misfire检查调度停止期间错过的启动时间，按任务的Misfire设置生成执行结构补执行。
+//最后一次启动时间从任务的执行日志获取，没有任务日志时使用调度日志。
+//错过的批次按启动时间先后依次执行，前一批次结束后再执行下一批次。
+func (s *Schedule) Misfire() { // {{{
+	now := time.Now()
+	missed := make(map[int64]map[int64]bool) //启动时间(秒)对应的任务列表
+	for _, t := range s.Tasks {
+		if t.Misfire == 0 || t.Disabled != 0 || t.TaskType != 1 || t.timer == nil {
+			continue
+		}
+
+		last, err := t.getLastRunTime(s.Id)
+		if err != nil {
+			g.L.Warningf("[s.Misfire] get task [%d %s] last run time error %s.\n", t.Id, t.Name, err.Error())
+			continue
+		}
+		if t.CreateTime != nil && t.CreateTime.After(last) {
+			last = *t.CreateTime
+		}
+		if last.IsZero() {
+			continue
+		}
+
+		times := make([]time.Time, 0)
+		for n := t.timer.Next(last); !n.IsZero() && n.Before(now); n = t.timer.Next(n) {
+			times = append(times, n)
+		}
+		if len(times) == 0 {
+			continue
+		}
+		if t.Misfire == 1 {
+			times = times[len(times)-1:]
+		} else if len(times) > maxMisfireCnt {
+			g.L.Warningf("[s.Misfire] task [%d %s] missed %d times, only the last %d will run.\n",
+				t.Id, t.Name, len(times), maxMisfireCnt)
+			times = times[len(times)-maxMisfireCnt:]
+		}
+		g.L.Infof("[s.Misfire] task [%d %s] missed %d times since %s.\n", t.Id, t.Name, len(times), last)
+
+		for _, tm := range times {
+			if _, ok := missed[tm.Unix()]; !ok {
+				missed[tm.Unix()] = make(map[int64]bool)
+			}
+			missed[tm.Unix()][t.Id] = true
+		}
+	}
+
+	runTimes := make([]int, 0, len(missed))
+	for tm, ids := range missed {
+		s.addDependTasks(ids)
+		runTimes = append(runTimes, int(tm))
+	}
+	sort.Ints(runTimes)
+
+	for _, tm := range runTimes {
+		//刷新前已经启动的批次不再补执行
+		if g.Schedules.isRunning(s.Id, time.Unix(int64(tm), 0)) {
+			continue
+		}
+		es := newExecSchedule(s, time.Unix(int64(tm), 0), 4, missed[int64(tm)])
+		if err := s.initExecSchedule(es); err != nil {
+			g.L.Warnf("[s.Misfire] %s\n", err.Error())
+			continue
+		}
+		es.Run()
+	}
+} // }}}
+
+//BackfillPeriods返回start至end之间按调度周期划分的各周期开始时间。
+//周期数量超过maxBackfillCnt时返回error信息。
+func (s *Schedule) BackfillPeriods(start, end time.Time) ([]time.Time, error) { // {{{
+	if s.Cyc == "" || !isCycle(s.Cyc) {
+		e := fmt.Sprintf("\n[s.BackfillPeriods] schedule [%d %s] unsupported cycle [%s].", s.Id, s.Name, s.Cyc)
+		return nil, errors.New(e)
+	}
+	if end.Before(start) {
+		e := fmt.Sprintf("\n[s.BackfillPeriods] end time %s is before start time %s.", end, start)
+		return nil, errors.New(e)
+	}
+
+	periods := make([]time.Time, 0)
+	for p := TruncDate(s.Cyc, start); !p.After(end); p = AddCycle(s.Cyc, p, 1) {
+		if len(periods) >= maxBackfillCnt {
+			e := fmt.Sprintf("\n[s.BackfillPeriods] too many periods, max %d.", maxBackfillCnt)
+			return nil, errors.New(e)
+		}
+		periods = append(periods, p)
+	}
+	return periods, nil
+} // }}}
+
+//Backfill按周期回填执行调度，每个周期生成一个执行类型为5的执行结构，启动时间为周期开始时间。
+//taskIds为空时执行调度中全部未禁用的任务，否则只执行指定的任务。
+//parallel为同时执行的周期数量，最小为1。全部周期执行完成后返回。
+func (s *Schedule) Backfill(taskIds []int64, periods []time.Time, parallel int) error { // {{{
+	ids := make(map[int64]bool)
+	if len(taskIds) == 0 {
+		for _, t := range s.Tasks {
+			if t.Disabled == 0 {
+				ids[t.Id] = true
+			}
+		}
+	} else {
+		for _, id := range taskIds {
+			if s.GetTaskById(id) == nil {
+				e := fmt.Sprintf("\n[s.Backfill] not found task [%d] in schedule [%d %s].", id, s.Id, s.Name)
+				return errors.New(e)
+			}
+			ids[id] = true
+		}
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	g.L.Infof("[s.Backfill] schedule [%d %s] backfill %d periods, %d tasks, parallel %d.\n",
+		s.Id, s.Name, len(periods), len(ids), parallel)
+
+	var wg sync.WaitGroup
+	sem := make(chan bool, parallel)
+	for _, p := range periods {
+		sem <- true
+		es := newExecSchedule(s, p, 5, ids)
+		if err := s.initExecSchedule(es); err != nil {
+			g.L.Warnf("[s.Backfill] period %s %s\n", p, err.Error())
+			<-sem
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			es.Run()
+		}()
+	}
+	wg.Wait()
+
+	g.L.Infof("[s.Backfill] schedule [%d %s] backfill is end.\n", s.Id, s.Name)
+	return nil
+} // }}}
+
+//Trigger手动启动调度的一个执行批次，执行类型为2，批次中包含调度中全部未禁用的任务。
+//chain为触发链上各级批次所属的调度id，由schedule执行器用来检查循环触发。
+//返回构建完成的执行结构，由调用方执行其Run方法。被隔离的调度不能启动。
+func (s *Schedule) Trigger(runTime time.Time, chain []int64) (*ExecSchedule, error) { // {{{
+	es, err := s.trigger(runTime, 2, nil, nil, chain)
+	if err != nil {
+		e := fmt.Sprintf("\n[s.Trigger] %s", err.Error())
+		return nil, errors.New(e)
+	}
+
+	return es, nil
+} // }}}
+
+//trigger构建执行类型为execType的执行批次，ids为空时包含调度中全部未禁用的任务。
+//conf为批次的参数，供任务渲染命令及设置环境变量使用，chain为触发链。
+func (s *Schedule) trigger(runTime time.Time, execType int8, ids map[int64]bool, conf map[string]interface{}, chain []int64) (*ExecSchedule, error) { // {{{
+	if s.State == 2 {
+		e := fmt.Sprintf("\n[s.trigger] schedule [%d %s] is quarantined.", s.Id, s.Name)
+		return nil, errors.New(e)
+	}
+
+	if len(ids) == 0 {
+		ids = make(map[int64]bool)
+		for _, t := range s.Tasks {
+			if t.Disabled == 0 {
+				ids[t.Id] = true
+			}
+		}
+	}
+	es := newExecSchedule(s, runTime, execType, ids)
+	es.conf = conf
+	es.chain = chain
+	if err := s.initExecSchedule(es); err != nil {
+		e := fmt.Sprintf("\n[s.trigger] %s", err.Error())
+		return nil, errors.New(e)
+	}
+
+	return es, nil
+} // }}}
+
+//addDependTasks将依赖ids中任务启动的依赖任务加入ids中，汇合启动的任务由joinDone单独启动
+func (s *Schedule) addDependTasks(ids map[int64]bool) { // {{{
+	for added := true; added; {
+		added = false
+		for _, t := range s.Tasks {
+			if t.TaskType != 2 || t.Disabled != 0 || t.JoinWindow > 0 || len(t.RelTasksId) == 0 || ids[t.Id] {
+				continue
+			}
+			if ids[t.RelTasksId[0]] {
+				ids[t.Id] = true
+				added = true
+			}
+		}
+	}
+} // }}}
+
 //从元数据库初始化Schedule结构，先从元数据库获取Schedule的信息，完成后
 //根据其中的Jobid继续从元数据库读取job信息，并初始化。完成后继续初始化下级Job，
 //同时将初始化完成的Job和Task添加到Schedule的Jobs、Tasks成员中。
@@ -269,6 +629,7 @@ func (s *Schedule) InitSchedule() error { // {{{
 	err := s.getSchedule()
 	s.updateTaskChan = make(chan *Task, 2)
 	s.doTaskChan = make(chan *Task, 2)
+	s.wakeChan = make(chan bool, 1)
 	//s.delTaskChan = make(chan int64, 2)
 	if err != nil {
 		e := fmt.Sprintf("\n[s.InitSchedule] get schedule [%d] error %s.", s.Id, err.Error())
@@ -282,9 +643,32 @@ func (s *Schedule) InitSchedule() error { // {{{
 			return errors.New(e)
 		}
 	}
+	//设置作业链
+	for _, tj := range s.Jobs {
+		if tj.PreJobId > 0 {
+			tj.PreJob, _ = s.GetJobById(tj.PreJobId)
+		}
+		if tj.NextJobId > 0 {
+			tj.NextJob, _ = s.GetJobById(tj.NextJobId)
+		}
+	}
+	s.JobCnt = len(s.Jobs)
 	g.L.Infof("Init Schedule[%s] End ...\n", s.Name)
 	return nil
 } // }}}
+//将执行结构加入全局列表，并构建执行链。
+//失败时将其从列表中移除，返回error信息。
+func (s *Schedule) initExecSchedule(es *ExecSchedule) error { // {{{
+	g.Schedules.AddExecSchedule(es)
+	if err := es.InitExecSchedule(); err != nil {
+		g.Schedules.RemoveExecSchedule(es.batchId)
+		e := fmt.Sprintf("\n[s.initExecSchedule] Init Execschedule [%d %s] error %s.", s.Id, s.Name, err.Error())
+		return errors.New(e)
+	}
+	g.L.Infof("[s.initExecSchedule] schedule [%d %s] is start batchId=[%s] execType=%d.\n", s.Id, s.Name, es.batchId, es.execType)
+	return nil
+} // }}}
+
 func (s *Schedule) UpdateTask(t *Task) {
 	s.updateTaskChan <- t
 }
@@ -297,12 +681,25 @@ func (s *Schedule) DoTask(tasks *Task) {
 func (s *Schedule) refresh() { // {{{
 	//发送消息停止监听
 	s.isRefresh <- true
-	//启动监听，按时启动Schedule
-	go s.Timer()
+	s.start()
 
 	return
 } // }}}
 
+//start启动监听按时启动Schedule，并补执行停止期间错过的任务。
+//启动、按id启动及刷新Schedule时都经过这里。
+func (s *Schedule) start() { // {{{
+	go s.Timer()
+	go s.Misfire()
+} // }}}
+
+//resolveRelTasks设置调度中全部任务依赖的任务，需在依赖的任务所属调度初始化后调用
+func (s *Schedule) resolveRelTasks() { // {{{
+	for _, t := range s.Tasks {
+		t.resolveRelTasks(s)
+	}
+} // }}}
+
 //addTaskList将传入的*Task添加到*Schedule.Tasks中
 func (s *Schedule) addTaskList(t *Task) { // {{{
 	s.Tasks = append(s.Tasks, t)
@@ -353,7 +750,7 @@ func (s *Schedule) DeleteTask(id int64) error { // {{{
 
 	err = t.Delete()
 	if err != nil {
-		e := fmt.Sprintf("\n[s.DeleteTask] schedule [%d] Delete error %s.", err.Error())
+		e := fmt.Sprintf("\n[s.DeleteTask] schedule [%d] Delete error %s.", s.Id, err.Error())
 		return errors.New(e)
 	}
 
@@ -387,6 +784,11 @@ func (s *Schedule) AddJob(job *Job) error { // {{{
 			e := fmt.Sprintf("\n[s.AddJob] update job [%d] error %s.", job.Id, err.Error())
 			return errors.New(e)
 		}
+		job.PreJobId = j.Id
+		if err = job.update(); err != nil {
+			e := fmt.Sprintf("\n[s.AddJob] update job [%d] error %s.", job.Id, err.Error())
+			return errors.New(e)
+		}
 	}
 	s.Jobs = append(s.Jobs, job)
 	s.JobCnt = len(s.Jobs)
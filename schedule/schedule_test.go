@@ -0,0 +1,159 @@
+package schedule
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackfillPeriods(t *testing.T) { // {{{
+	cases := []struct {
+		name  string
+		cyc   string
+		start time.Time
+		end   time.Time
+		want  []time.Time
+	}{
+		{"same day", "d", date(2026, 1, 10, 8, 0, 0), date(2026, 1, 10, 9, 0, 0),
+			[]time.Time{date(2026, 1, 10, 0, 0, 0)}},
+		{"days inclusive", "d", date(2026, 1, 10, 0, 0, 0), date(2026, 1, 12, 0, 0, 0),
+			[]time.Time{date(2026, 1, 10, 0, 0, 0), date(2026, 1, 11, 0, 0, 0), date(2026, 1, 12, 0, 0, 0)}},
+		{"start truncated", "h", date(2026, 1, 10, 8, 30, 0), date(2026, 1, 10, 10, 15, 0),
+			[]time.Time{date(2026, 1, 10, 8, 0, 0), date(2026, 1, 10, 9, 0, 0), date(2026, 1, 10, 10, 0, 0)}},
+		{"months", "m", date(2025, 11, 20, 0, 0, 0), date(2026, 1, 5, 0, 0, 0),
+			[]time.Time{date(2025, 11, 1, 0, 0, 0), date(2025, 12, 1, 0, 0, 0), date(2026, 1, 1, 0, 0, 0)}},
+		{"quarters", "q", date(2026, 2, 1, 0, 0, 0), date(2026, 7, 1, 0, 0, 0),
+			[]time.Time{date(2026, 1, 1, 0, 0, 0), date(2026, 4, 1, 0, 0, 0), date(2026, 7, 1, 0, 0, 0)}},
+	}
+	for _, c := range cases {
+		s := &Schedule{Id: 1, Name: "s", Cyc: c.cyc}
+		got, err := s.BackfillPeriods(c.start, c.end)
+		if err != nil {
+			t.Fatalf("%s: BackfillPeriods() error %s", c.name, err.Error())
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: BackfillPeriods() = %v, want %v", c.name, got, c.want)
+		}
+		for i := range got {
+			if !got[i].Equal(c.want[i]) {
+				t.Errorf("%s: period %d = %s, want %s", c.name, i, got[i], c.want[i])
+			}
+		}
+	}
+
+	errs := []struct {
+		name  string
+		cyc   string
+		start time.Time
+		end   time.Time
+	}{
+		{"no cycle", "", date(2026, 1, 1, 0, 0, 0), date(2026, 1, 2, 0, 0, 0)},
+		{"bad cycle", "x", date(2026, 1, 1, 0, 0, 0), date(2026, 1, 2, 0, 0, 0)},
+		{"end before start", "d", date(2026, 1, 2, 0, 0, 0), date(2026, 1, 1, 0, 0, 0)},
+		{"too many", "mi", date(2026, 1, 1, 0, 0, 0), date(2026, 1, 2, 0, 0, 0)},
+	}
+	for _, c := range errs {
+		s := &Schedule{Id: 1, Name: "s", Cyc: c.cyc}
+		if _, err := s.BackfillPeriods(c.start, c.end); err == nil {
+			t.Errorf("%s: BackfillPeriods() = nil error, want error", c.name)
+		}
+	}
+} // }}}
+
+//已有相同启动时间的批次执行中时，补执行跳过该启动时间
+func TestIsRunning(t *testing.T) { // {{{
+	runTime := date(2026, 1, 10, 1, 0, 0)
+	s := &Schedule{Id: 1, Name: "s"}
+	sl := &ScheduleManager{ExecScheduleList: map[string]*ExecSchedule{
+		"b": {batchId: "b", schedule: s, runTime: runTime},
+	}}
+
+	cases := []struct {
+		scdId   int64
+		runTime time.Time
+		want    bool
+	}{
+		{1, runTime, true},
+		{1, runTime.Add(time.Hour), false},
+		{2, runTime, false},
+	}
+	for _, c := range cases {
+		if got := sl.isRunning(c.scdId, c.runTime); got != c.want {
+			t.Errorf("isRunning(%d, %s) = %v, want %v", c.scdId, c.runTime, got, c.want)
+		}
+	}
+} // }}}
+
+//自动定时调度的批次无论正常结束、启动失败还是异常退出，都从执行中的数量减去
+func TestRunReleasesRunning(t *testing.T) { // {{{
+	cases := []struct {
+		name     string
+		execType int8
+		fail     bool //写入调度日志失败
+		panic    bool //执行任务时出现异常
+		running  int
+	}{
+		{"done", 1, false, false, 0},
+		{"start failed", 1, true, false, 0},
+		{"panic", 1, false, true, 0},
+		{"manual", 2, false, false, 1},
+	}
+	for _, c := range cases {
+		useTestDB(t)
+		if c.fail {
+			testErrs["scd_schedule_log"] = errors.New("log failed")
+		}
+		s := &Schedule{Id: 0, Name: "s", running: 1}
+		es := &ExecSchedule{schedule: s, batchId: "b", execType: c.execType,
+			execTasks: make(map[int64]*ExecTask), runTasks: make(map[int64]*ExecTask)}
+		if c.panic {
+			es.execTasks[1] = &ExecTask{id: 1}
+			es.taskCnt = 1
+		}
+		es.Run()
+		if s.running != c.running {
+			t.Errorf("%s: running = %d, want %d", c.name, s.running, c.running)
+		}
+	}
+} // }}}
+
+//修复执行只执行原批次中未成功的任务，依赖已成功任务的任务直接执行
+func TestRestore(t *testing.T) { // {{{
+	useTestDB(t)
+	g.Port = ":1"
+	runTime := date(2026, 1, 10, 0, 0, 0)
+	testRows["scd_schedule_log"] = &testResult{columns: []string{"run_time"}, values: [][]driver.Value{{runTime}}}
+	testRows["scd_task_log"] = &testResult{columns: []string{"task_id"}, values: [][]driver.Value{{int64(1)}}}
+
+	t1 := &Task{Id: 1, Name: "t1", JobId: 1, TaskCyc: "d", Address: "127.0.0.1"}
+	t2 := &Task{Id: 2, Name: "t2", JobId: 1, TaskCyc: "d", Address: "127.0.0.1",
+		RelTasksId: []int64{1}, RelTasks: map[string]*Task{"1": t1}}
+	j := &Job{Id: 1, Name: "j", Tasks: map[string]*Task{"1": t1, "2": t2}}
+	s := &Schedule{Id: 1, Name: "s", Cyc: "d", Jobs: []*Job{j}, Tasks: []*Task{t1, t2}, JobCnt: 1, TaskCnt: 2}
+	g.Schedules.ScheduleList = []*Schedule{s}
+
+	done := make(chan error, 1)
+	go func() { done <- Restore("b", 0) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Restore() error %s", err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Restore() not finished")
+	}
+
+	n := 0
+	testLock.Lock()
+	for _, q := range testExecs {
+		if strings.Contains(q, "INSERT INTO scd_task_log") {
+			n++
+		}
+	}
+	testLock.Unlock()
+	if n != 1 {
+		t.Errorf("restore logged %d task runs, want 1", n)
+	}
+} // }}}
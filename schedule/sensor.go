@@ -0,0 +1,61 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	//传感器默认的检查间隔，单位秒
+	defaultSensorInterval = 60
+)
+
+//sense按传感器方式执行任务：每隔属性sensor_interval秒将检查命令发送给执行模块执行一次，
+//命令执行成功即条件满足后返回。检查失败不计入重试次数，等待期间任务状态为6(等待)。
+//超过属性sensor_timeout秒仍未满足时返回error，sensor_timeout未设置或为0时一直等待。
+//每次检查单独占用执行名额，检查之间不占用执行模块的连接。
+func (et *ExecTask) sense(task *CmdTask, rl *Reply) (err error) { // {{{
+	t := et.task
+	interval := defaultSensorInterval
+	if v, e := strconv.Atoi(t.Attr["sensor_interval"]); e == nil && v > 0 {
+		interval = v
+	}
+	var deadline <-chan time.Time
+	timeout, _ := strconv.Atoi(t.Attr["sensor_timeout"])
+	if timeout > 0 {
+		deadline = time.After(time.Duration(timeout) * time.Second)
+	}
+
+	task.Attempt = 1
+	logged := false
+	for pokes := 1; !et.aborted; pokes++ {
+		if err = et.call(task, rl); err == nil && rl.Err == "" {
+			g.L.Infoln("task", t.Name, "sensor is satisfied after", pokes, "pokes batchTaskId[", et.batchTaskId, "]")
+			et.state = 3
+			et.errstr = ""
+			return nil
+		}
+
+		if !logged {
+			et.state = 6
+			et.errstr = fmt.Sprintf("sensor is waiting, poke every %d seconds.", interval)
+			if e := et.Log(); e != nil {
+				g.L.Warnf("[et.sense] %s\n", e.Error())
+			}
+			logged = true
+		}
+
+		select {
+		case <-deadline:
+			e := fmt.Sprintf("\n[et.sense] task [%d %s] sensor timeout %d seconds after %d pokes.", t.Id, t.Name, timeout, pokes)
+			et.errType = 3
+			et.errstr = e
+			return errors.New(e)
+		case <-time.After(time.Duration(interval) * time.Second):
+		}
+	}
+
+	return err
+} // }}}
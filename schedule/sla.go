@@ -0,0 +1,237 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//slaDeadline按周期开始时间计算SLA的截止时间，spec为空或格式错误时返回false。
+//spec支持两种格式：
+//  秒数       相对于周期开始时间的偏移，如 7200 表示周期开始后2小时
+//  hh:mi[:ss] 周期开始当天的时刻，早于周期开始时间时为次日的该时刻
+func slaDeadline(spec string, periodStart time.Time) (time.Time, bool) { // {{{
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return time.Time{}, false
+	}
+
+	if !strings.Contains(spec, ":") {
+		sec, err := strconv.ParseInt(spec, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return periodStart.Add(time.Duration(sec) * time.Second), true
+	}
+
+	var h, m, sec int
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 {
+		return time.Time{}, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return time.Time{}, false
+		}
+		switch i {
+		case 0:
+			h = n
+		case 1:
+			m = n
+		case 2:
+			sec = n
+		}
+	}
+	if h > 23 || m > 59 || sec > 59 {
+		return time.Time{}, false
+	}
+
+	d := time.Date(periodStart.Year(), periodStart.Month(), periodStart.Day(), h, m, sec, 0, time.Local)
+	if d.Before(periodStart) {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d, true
+} // }}}
+
+//checkSla检查批次及其中任务的SLA，未达成的写入scd_sla_miss并发出slamiss告警。
+//任务的截止时间按任务的调度周期计算，调度的截止时间按调度周期计算。
+//同一批次中同一对象的同类SLA只记录一次，执行中的任务超时也会立即记录。
+//批次结束后仍未开始或未完成的任务在本批次中不会再执行，截止时间未到也记录。
+func (es *ExecSchedule) checkSla() { // {{{
+	if es.slaMissed == nil {
+		es.slaMissed = make(map[string]bool)
+	}
+	now := time.Now()
+	final := es.endTime != nil
+
+	for _, et := range es.execTasks {
+		t := et.task
+		if et.state == 5 {
+			continue
+		}
+		start, _ := et.period()
+
+		if d, ok := slaDeadline(t.SlaStart, start); ok {
+			if (et.startTime == nil && (final || now.After(d))) || (et.startTime != nil && et.startTime.After(d)) {
+				es.slaMiss(et, "start", d, fmt.Sprintf("task not started by %s", d.Format("2006-01-02 15:04:05")))
+			}
+		}
+
+		if d, ok := slaDeadline(t.SlaFinish, start); ok {
+			if (et.endTime == nil && (final || now.After(d))) || (et.endTime != nil && et.endTime.After(d)) {
+				es.slaMiss(et, "finish", d, fmt.Sprintf("task not finished by %s", d.Format("2006-01-02 15:04:05")))
+			}
+		}
+
+		if t.SlaDuration > 0 && et.startTime != nil {
+			end := now
+			if et.endTime != nil {
+				end = *et.endTime
+			}
+			d := et.startTime.Add(time.Duration(t.SlaDuration) * time.Second)
+			if end.After(d) {
+				es.slaMiss(et, "duration", d, fmt.Sprintf("task has run longer than %d seconds", t.SlaDuration))
+			}
+		}
+	}
+
+	s := es.schedule
+	start := es.runTime
+	if s.Cyc != "" {
+		start = TruncDate(s.Cyc, es.runTime)
+	}
+
+	if d, ok := slaDeadline(s.SlaStart, start); ok {
+		if (es.startTime == nil && (final || now.After(d))) || (es.startTime != nil && es.startTime.After(d)) {
+			es.slaMiss(nil, "start", d, fmt.Sprintf("schedule not started by %s", d.Format("2006-01-02 15:04:05")))
+		}
+	}
+
+	if d, ok := slaDeadline(s.SlaFinish, start); ok {
+		if (es.endTime == nil && now.After(d)) || (es.endTime != nil && es.endTime.After(d)) {
+			es.slaMiss(nil, "finish", d, fmt.Sprintf("schedule not finished by %s", d.Format("2006-01-02 15:04:05")))
+		}
+	}
+
+	if s.SlaDuration > 0 && es.startTime != nil {
+		end := now
+		if es.endTime != nil {
+			end = *es.endTime
+		}
+		d := es.startTime.Add(time.Duration(s.SlaDuration) * time.Second)
+		if end.After(d) {
+			es.slaMiss(nil, "duration", d, fmt.Sprintf("schedule has run longer than %d seconds", s.SlaDuration))
+		}
+	}
+} // }}}
+
+//slaMiss记录一次SLA未达成并发出告警，et为nil时表示调度级别的SLA。
+func (es *ExecSchedule) slaMiss(et *ExecTask, missType string, deadline time.Time, msg string) { // {{{
+	key := "schedule:" + missType
+	if et != nil {
+		key = fmt.Sprintf("%d:%s", et.id, missType)
+	}
+	if es.slaMissed[key] {
+		return
+	}
+	es.slaMissed[key] = true
+
+	s := es.schedule
+	ev := &AlertEvent{
+		Type:         "slamiss",
+		ScheduleId:   s.Id,
+		ScheduleName: s.Name,
+		BatchId:      es.batchId,
+		Message:      msg,
+	}
+	var batchTaskId string
+	if et != nil {
+		ev.TaskId, ev.TaskName = et.task.Id, et.task.Name
+		batchTaskId = et.batchTaskId
+	}
+
+	if err := addSlaMiss(s.Id, ev.TaskId, es.batchId, batchTaskId, missType, deadline); err != nil {
+		g.L.Warningln(fmt.Sprintf("\n[es.slaMiss] %s", err.Error()))
+	}
+	Alert(ev)
+} // }}}
+
+//StartSlaChecker启动后台检查，定时核对设置了SLA的调度在各周期内是否有批次执行。
+//调度停止、被隔离或定时器未启动时没有批次，批次内的检查无法发现，由这里记录。
+func (sl *ScheduleManager) StartSlaChecker() { // {{{
+	go func() {
+		tick := time.NewTicker(slaCheckInterval)
+		defer tick.Stop()
+		for now := range tick.C {
+			for _, s := range sl.ScheduleList {
+				if err := s.checkSla(now); err != nil {
+					g.L.Warningln(fmt.Sprintf("\n[sl.StartSlaChecker] %s", err.Error()))
+				}
+			}
+		}
+	}()
+} // }}}
+
+//checkSla检查调度的上一周期及当前周期，截止时间已过但周期内没有批次时记录SLA未达成。
+//检查上一周期是为了发现调度停止期间错过的截止时间，周期内有批次时由批次自身检查。
+//执行次数已满的调度不会再有批次，不检查。
+func (s *Schedule) checkSla(now time.Time) error { // {{{
+	if s.Cyc == "" || s.State == 1 || (s.SlaStart == "" && s.SlaFinish == "") {
+		return nil
+	}
+
+	cur := TruncDate(s.Cyc, now)
+	for _, start := range []time.Time{AddCycle(s.Cyc, cur, -1), cur} {
+		end := AddCycle(s.Cyc, start, 1)
+
+		type miss struct {
+			missType string
+			deadline time.Time
+			msg      string
+		}
+		//截止时间在调度创建前的不检查
+		misses := make([]miss, 0, 2)
+		if d, ok := slaDeadline(s.SlaStart, start); ok && now.After(d) && d.After(s.CreateTime) {
+			misses = append(misses, miss{"start", d, fmt.Sprintf("schedule has no batch started by %s", d.Format("2006-01-02 15:04:05"))})
+		}
+		if d, ok := slaDeadline(s.SlaFinish, start); ok && now.After(d) && d.After(s.CreateTime) {
+			misses = append(misses, miss{"finish", d, fmt.Sprintf("schedule has no batch finished by %s", d.Format("2006-01-02 15:04:05"))})
+		}
+		if len(misses) == 0 {
+			continue
+		}
+
+		cnt, err := getBatchCnt(s.Id, start, end)
+		if err != nil {
+			return errors.New(fmt.Sprintf("\n[s.checkSla] %s", err.Error()))
+		}
+		if cnt > 0 {
+			continue
+		}
+
+		for _, m := range misses {
+			missed, err := isSlaMissed(s.Id, 0, m.missType, m.deadline)
+			if err != nil {
+				return errors.New(fmt.Sprintf("\n[s.checkSla] %s", err.Error()))
+			}
+			if missed {
+				continue
+			}
+
+			if err = addSlaMiss(s.Id, 0, "", "", m.missType, m.deadline); err != nil {
+				return errors.New(fmt.Sprintf("\n[s.checkSla] %s", err.Error()))
+			}
+			Alert(&AlertEvent{
+				Type:         "slamiss",
+				ScheduleId:   s.Id,
+				ScheduleName: s.Name,
+				Message:      m.msg,
+			})
+		}
+	}
+
+	return nil
+} // }}}
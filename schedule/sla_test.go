@@ -0,0 +1,133 @@
+package schedule
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlaDeadline(t *testing.T) { // {{{
+	start := time.Date(2026, 1, 10, 0, 0, 0, 0, time.Local)
+	noon := time.Date(2026, 1, 10, 12, 0, 0, 0, time.Local)
+	cases := []struct {
+		spec  string
+		start time.Time
+		want  time.Time
+		ok    bool
+	}{
+		{"", start, time.Time{}, false},
+		{"  ", start, time.Time{}, false},
+		{"7200", start, start.Add(2 * time.Hour), true},
+		{" 60 ", start, start.Add(time.Minute), true},
+		{"0", start, start, true},
+		{"abc", start, time.Time{}, false},
+		{"06:30", start, time.Date(2026, 1, 10, 6, 30, 0, 0, time.Local), true},
+		{"06:30:15", start, time.Date(2026, 1, 10, 6, 30, 15, 0, time.Local), true},
+		{"06:30", noon, time.Date(2026, 1, 11, 6, 30, 0, 0, time.Local), true},
+		{"12:00", noon, noon, true},
+		{"24:00", start, time.Time{}, false},
+		{"06:60", start, time.Time{}, false},
+		{"06:30:60", start, time.Time{}, false},
+		{"06:-1", start, time.Time{}, false},
+		{"1:2:3:4", start, time.Time{}, false},
+		{"06:xx", start, time.Time{}, false},
+	}
+	for _, c := range cases {
+		got, ok := slaDeadline(c.spec, c.start)
+		if ok != c.ok || !got.Equal(c.want) {
+			t.Errorf("slaDeadline(%q, %s) = %s, %v, want %s, %v", c.spec, c.start, got, ok, c.want, c.ok)
+		}
+	}
+} // }}}
+
+//slaMissCnt返回写入scd_sla_miss的次数
+func slaMissCnt() int { // {{{
+	testLock.Lock()
+	defer testLock.Unlock()
+	n := 0
+	for _, q := range testExecs {
+		if strings.Contains(q, "INSERT INTO scd_sla_miss") {
+			n++
+		}
+	}
+	return n
+} // }}}
+
+//周期内没有批次时由后台检查记录SLA未达成，有批次或已记录时不再记录
+func TestScheduleCheckSla(t *testing.T) { // {{{
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.Local)
+	count := func(n int64) *testResult {
+		return &testResult{columns: []string{"count(*)"}, values: [][]driver.Value{{n}}}
+	}
+	cases := []struct {
+		name    string
+		state   int8
+		created time.Time
+		rows    map[string]*testResult
+		want    int
+	}{
+		//上一周期的开始、完成及当前周期的开始
+		{"no batch", 0, now.AddDate(0, -1, 0), nil, 3},
+		{"created today", 0, now.Add(-11*time.Hour - 30*time.Minute), nil, 1},
+		{"created after deadline", 0, now.Add(-time.Hour), nil, 0},
+		{"batch ran", 0, now.AddDate(0, -1, 0), map[string]*testResult{"scd_schedule_log": count(1)}, 0},
+		{"recorded", 0, now.AddDate(0, -1, 0), map[string]*testResult{"scd_sla_miss": count(1)}, 0},
+		{"exhausted", 1, now.AddDate(0, -1, 0), nil, 0},
+	}
+	for _, c := range cases {
+		useTestDB(t)
+		testLock.Lock()
+		for k, v := range c.rows {
+			testRows[k] = v
+		}
+		testLock.Unlock()
+
+		s := &Schedule{Id: 1, Name: "s", Cyc: "d", State: c.state, CreateTime: c.created,
+			SlaStart: "3600", SlaFinish: "13:00"}
+		if err := s.checkSla(now); err != nil {
+			t.Fatalf("%s: checkSla() error %s", c.name, err.Error())
+		}
+		if n := slaMissCnt(); n != c.want {
+			t.Errorf("%s: recorded %d sla misses, want %d", c.name, n, c.want)
+		}
+	}
+
+	//未设置周期或SLA的调度不检查
+	useTestDB(t)
+	for _, s := range []*Schedule{{Id: 2, SlaStart: "3600"}, {Id: 3, Cyc: "d"}} {
+		if err := s.checkSla(now); err != nil {
+			t.Fatalf("schedule [%d] checkSla() error %s", s.Id, err.Error())
+		}
+	}
+	if n := slaMissCnt(); n != 0 {
+		t.Errorf("recorded %d sla misses without cyc or sla, want 0", n)
+	}
+} // }}}
+
+//批次结束时仍未开始、未完成的任务即使截止时间未到也记录
+func TestCheckSlaBatchEnd(t *testing.T) { // {{{
+	runTime := TruncDate("d", time.Now())
+	task := &Task{Id: 1, Name: "t", ScheduleCyc: "d", SlaStart: "172800", SlaFinish: "172800"}
+	s := &Schedule{Id: 1, Name: "s", Cyc: "d", Tasks: []*Task{task}}
+	ej := &ExecJob{batchJobId: "b.1", batchId: "b", runTime: runTime, execTasks: make(map[int64]*ExecTask)}
+
+	for _, final := range []bool{false, true} {
+		useTestDB(t)
+		es := &ExecSchedule{batchId: "b", schedule: s, runTime: runTime, startTime: &runTime,
+			execTasks: map[int64]*ExecTask{task.Id: ExecTaskWarper(ej, task)}}
+		if final {
+			es.endTime = NowTimePtr()
+		}
+		es.checkSla()
+
+		for _, missType := range []string{"start", "finish"} {
+			if got := es.slaMissed["1:"+missType]; got != final {
+				t.Errorf("final=%v: task %s missed = %v, want %v", final, missType, got, final)
+			}
+		}
+		if es.slaMissed["schedule:start"] {
+			t.Errorf("final=%v: schedule started on time but start sla missed", final)
+		}
+	}
+} // }}}
@@ -0,0 +1,209 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//sql执行器输出的最大行数
+	maxSqlRows = 100
+)
+
+var (
+	//执行中的sql任务的取消函数，key为任务批次ID
+	sqlSessions    = make(map[string]context.CancelFunc)
+	sqlSessionLock sync.Mutex
+)
+
+//runSql按sql执行器执行任务，任务的命令为sql语句，在属性sql_conn指定名称的数据库链接上执行。
+//查询语句输出结果集的前maxSqlRows行，其它语句输出影响的行数。
+//属性sql_assert设置断言，格式为 运算符 值，如 "> 0"，运算符可以是 > >= < <= == !=，
+//查询语句以第一行第一列、其它语句以影响的行数与值比较，不满足时任务失败。
+func (et *ExecTask) runSql(task *CmdTask, rl *Reply) { // {{{
+	name := task.Attr["sql_conn"]
+	db, ok := g.DataConns[name]
+	if !ok {
+		rl.Err = fmt.Sprintf("sql connection [%s] is not configured.", name)
+		return
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if task.TimeOut > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(task.TimeOut)*time.Second)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+	sqlSessionLock.Lock()
+	sqlSessions[et.batchTaskId] = cancel
+	sqlSessionLock.Unlock()
+	defer func() {
+		sqlSessionLock.Lock()
+		delete(sqlSessions, et.batchTaskId)
+		sqlSessionLock.Unlock()
+	}()
+
+	stmt := strings.TrimSpace(task.Cmd)
+	var value string
+	var err error
+	if isQuery(stmt) {
+		value, rl.Stdout, err = querySql(ctx, db, stmt)
+	} else {
+		var n int64
+		n, err = execSql(ctx, db, stmt)
+		value = strconv.FormatInt(n, 10)
+		rl.Stdout = fmt.Sprintf("rows affected: %d\n", n)
+	}
+	if err != nil {
+		rl.Err = err.Error()
+		if ctx.Err() == context.DeadlineExceeded {
+			rl.ErrType = 3
+		}
+		return
+	}
+
+	if expr := task.Attr["sql_assert"]; expr != "" {
+		if ok, err := assertValue(value, expr); err != nil {
+			rl.Err = err.Error()
+		} else if !ok {
+			rl.Err = fmt.Sprintf("assertion failed, value [%s] is not %s.", value, expr)
+		}
+	}
+} // }}}
+
+//killSql取消执行中的sql任务，任务不在执行中时返回false
+func killSql(batchTaskId string) bool { // {{{
+	sqlSessionLock.Lock()
+	cancel, ok := sqlSessions[batchTaskId]
+	sqlSessionLock.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+} // }}}
+
+//isQuery判断语句是否返回结果集
+func isQuery(stmt string) bool { // {{{
+	fields := strings.Fields(stmt)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH", "SHOW", "DESC", "DESCRIBE", "EXPLAIN":
+		return true
+	}
+	return false
+} // }}}
+
+//querySql执行查询语句，返回第一行第一列的值以及制表符分隔的结果集
+func querySql(ctx context.Context, db *sql.DB, stmt string) (first string, output string, err error) { // {{{
+	rows, err := db.QueryContext(ctx, stmt)
+	if err != nil {
+		return "", "", err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", "", err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(strings.Join(cols, "\t") + "\n")
+
+	cnt := 0
+	for rows.Next() {
+		values := make([]sql.NullString, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return "", "", err
+		}
+
+		if cnt == 0 && len(values) > 0 {
+			first = values[0].String
+		}
+		if cnt < maxSqlRows {
+			line := make([]string, len(values))
+			for i, v := range values {
+				line[i] = v.String
+				if !v.Valid {
+					line[i] = "NULL"
+				}
+			}
+			buf.WriteString(strings.Join(line, "\t") + "\n")
+		}
+		cnt++
+	}
+	if err = rows.Err(); err != nil {
+		return "", "", err
+	}
+	if cnt > maxSqlRows {
+		buf.WriteString(fmt.Sprintf("... %d rows, only %d shown\n", cnt, maxSqlRows))
+	}
+
+	return first, buf.String(), nil
+} // }}}
+
+//execSql执行非查询语句，返回影响的行数
+func execSql(ctx context.Context, db *sql.DB, stmt string) (int64, error) { // {{{
+	result, err := db.ExecContext(ctx, stmt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+} // }}}
+
+//assertValue按断言表达式判断值是否满足条件，两边均为数字时按数值比较，否则按字符串比较
+func assertValue(value, expr string) (bool, error) { // {{{
+	expr = strings.TrimSpace(expr)
+	var op string
+	for _, o := range []string{">=", "<=", "==", "!=", ">", "<"} {
+		if strings.HasPrefix(expr, o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return false, fmt.Errorf("invalid sql_assert [%s].", expr)
+	}
+	want := strings.TrimSpace(expr[len(op):])
+
+	var cmp int
+	a, err1 := strconv.ParseFloat(value, 64)
+	b, err2 := strconv.ParseFloat(want, 64)
+	switch {
+	case err1 == nil && err2 == nil:
+		if a < b {
+			cmp = -1
+		} else if a > b {
+			cmp = 1
+		}
+	default:
+		cmp = strings.Compare(value, want)
+	}
+
+	switch op {
+	case ">=":
+		return cmp >= 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp < 0, nil
+	}
+} // }}}
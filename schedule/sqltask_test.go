@@ -0,0 +1,69 @@
+package schedule
+
+import (
+	"testing"
+)
+
+func TestIsQuery(t *testing.T) { // {{{
+	cases := []struct {
+		stmt string
+		want bool
+	}{
+		{"select 1", true},
+		{"  SELECT count(*) FROM t", true},
+		{"\n\twith a as (select 1) select * from a", true},
+		{"show tables", true},
+		{"desc t", true},
+		{"DESCRIBE t", true},
+		{"explain select 1", true},
+		{"insert into t select 1", false},
+		{"update t set a=1", false},
+		{"delete from t", false},
+		{"selectx", false},
+		{"", false},
+		{"   ", false},
+	}
+	for _, c := range cases {
+		if got := isQuery(c.stmt); got != c.want {
+			t.Errorf("isQuery(%q) = %v, want %v", c.stmt, got, c.want)
+		}
+	}
+} // }}}
+
+func TestAssertValue(t *testing.T) { // {{{
+	cases := []struct {
+		value string
+		expr  string
+		want  bool
+		err   bool
+	}{
+		{"10", "> 0", true, false},
+		{"0", ">0", false, false},
+		{"5", ">= 5", true, false},
+		{"4.9", ">=5", false, false},
+		{"5", "<= 5.0", true, false},
+		{"3", "< 10", true, false},
+		//按数值比较，按字符串比较时"10" < "9"
+		{"10", "> 9", true, false},
+		{"1.0", "== 1", true, false},
+		{"1", "!= 2", true, false},
+		{"ok", "== ok", true, false},
+		{"ok", "!= ok", false, false},
+		{"abc", "< abd", true, false},
+		{"", "== ", true, false},
+		{"1", "  == 1  ", true, false},
+		{"1", "1", false, true},
+		{"1", "=> 1", false, true},
+		{"1", "", false, true},
+	}
+	for _, c := range cases {
+		got, err := assertValue(c.value, c.expr)
+		if (err != nil) != c.err {
+			t.Errorf("assertValue(%q, %q) error = %v, want error %v", c.value, c.expr, err, c.err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("assertValue(%q, %q) = %v, want %v", c.value, c.expr, got, c.want)
+		}
+	}
+} // }}}
@@ -0,0 +1,54 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//runSchedule按schedule执行器执行任务，任务的命令为需要启动的调度id。
+//子调度以手动调度方式启动，批次启动时间与本任务所在批次相同，子批次ID写入标准输出，
+//并以结果child_batch_id发布给下级任务。
+//属性schedule_wait为1时等待子调度执行完成，子调度中有任务失败时本任务失败；否则启动后立即返回。
+//本任务被中止时不影响已启动的子调度。
+//子调度已在触发链中时(如A触发B，B又触发A)拒绝启动，避免无限循环触发。
+func (et *ExecTask) runSchedule(task *CmdTask, rl *Reply) { // {{{
+	id, err := strconv.ParseInt(strings.TrimSpace(task.Cmd), 10, 64)
+	if err != nil {
+		rl.Err = fmt.Sprintf("invalid schedule id [%s].", task.Cmd)
+		return
+	}
+	chain := append(append([]int64{}, et.execJob.chain...), et.execJob.job.ScheduleId)
+	for _, sid := range chain {
+		if sid == id {
+			rl.Err = fmt.Sprintf("schedule [%d] is already in the trigger chain %v.", id, chain)
+			return
+		}
+	}
+	s := g.Schedules.GetScheduleById(id)
+	if s == nil {
+		rl.Err = fmt.Sprintf("not found schedule [%d].", id)
+		return
+	}
+
+	es, err := s.Trigger(et.execJob.runTime, chain)
+	if err != nil {
+		rl.Err = err.Error()
+		return
+	}
+	g.L.Infoln("task", et.task.Name, "triggered schedule", s.Name, "batchId[", es.batchId, "] batchTaskId[", et.batchTaskId, "]")
+
+	out := fmt.Sprintf("%schild_batch_id=%s\n", resultMark, es.batchId)
+	if task.Attr["schedule_wait"] != "1" {
+		go es.Run()
+		rl.Stdout = out
+		return
+	}
+
+	es.Run()
+	rl.Stdout = out + fmt.Sprintf("schedule [%d %s] batchId=[%s] state=%d success=%d fail=%d result=%v\n",
+		s.Id, s.Name, es.batchId, es.state, es.successTaskCnt, es.failTaskCnt, es.result)
+	if es.state != 3 || es.failTaskCnt > 0 {
+		rl.Err = fmt.Sprintf("schedule [%d %s] batchId=[%s] failed, %d tasks failed.", s.Id, s.Name, es.batchId, es.failTaskCnt)
+	}
+} // }}}
@@ -0,0 +1,33 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+)
+
+//触发链中已有的调度不能再次被触发
+func TestRunScheduleChain(t *testing.T) { // {{{
+	useTestDB(t)
+
+	cases := []struct {
+		cmd   string
+		chain []int64
+		err   string
+	}{
+		{"1", nil, "trigger chain"},
+		{"2", []int64{2}, "trigger chain"},
+		{"3", []int64{3, 2}, "trigger chain"},
+		{"4", []int64{2, 3}, "not found schedule"},
+		{"x", nil, "invalid schedule id"},
+	}
+	for _, c := range cases {
+		ej := &ExecJob{batchJobId: "b.1", batchId: "b", job: &Job{ScheduleId: 1}, chain: c.chain,
+			execTasks: make(map[int64]*ExecTask)}
+		et := ExecTaskWarper(ej, &Task{Id: 1, Name: "sub", Executor: "schedule"})
+		rl := &Reply{}
+		et.runSchedule(&CmdTask{Cmd: c.cmd}, rl)
+		if !strings.Contains(rl.Err, c.err) {
+			t.Errorf("runSchedule(%s) chain %v error = %q, want %q", c.cmd, c.chain, rl.Err, c.err)
+		}
+	}
+} // }}}
@@ -17,11 +17,18 @@ type Task struct { // {{{
 	TaskCyc      string //调度周期
 	Cronstr      string //`json:"-"`
 	Retry        int
-	Concurrent   int
+	RetryDelay   int64             //首次重试前等待的时间，单位秒
+	Backoff      float64           //每次重试后等待时间的倍数，小于1时按1处理
+	MaxDelay     int64             //重试前等待的最长时间，单位秒，0不限制
+	RetryOn      int8              //需要重试的失败 0.全部失败 1.只重试执行模块无法连接及RPC调用错误 2.只重试命令执行失败，见errTypes
+	Concurrent   int               //任务同时执行的最大实例数量 0.不限制
+	Misfire      int8              //错过启动时间的处理方式 0.跳过 1.补执行一次 2.补执行全部错过的周期
+	Overlap      int8              //达到最大实例数量时的处理方式 0.跳过本次执行 1.排队等待 2.中止之前的执行
 	Disabled     int8              //`json:"-"`
 	Priority     int16             //`json:"-"`
 	StartSecond  time.Duration     //周期内启动时间
-	Cmd          string            // 任务执行的命令或脚本、函数名等。
+	StartList    string            //周期内的多个启动时间，设置后代替StartSecond，格式见ParseStartList
+	Cmd          string            // 任务执行的命令或脚本、函数名等，属性cmd_template为1时发送前按批次信息渲染，见renderCmd。
 	Desc         string            //任务说明
 	TimeOut      int64             // 设定超时时间，0表示不做超时限制。单位秒
 	Attr         map[string]string `json:"-"` // 任务的属性信息
@@ -29,6 +36,15 @@ type Task struct { // {{{
 	RelTasksId   []int64           //依赖的任务Id
 	RelTasks     map[string]*Task  //`json:"-"` //依赖的任务
 	RelTaskCnt   int64             //依赖的任务数量
+	ExtTasksId   []int64           //依赖的其它调度中的任务Id，按周期匹配其执行日志
+	RelWaitTime  int64             //等待其它调度中依赖任务的最长时间，单位秒，0使用默认的6小时
+	TriggerRule  int8              //依赖任务结束后的触发规则 0.全部成功 1.全部结束 2.任一失败 3.任一成功
+	JoinWindow   int64             //依赖任务的汇合时间窗口，单位秒，大于0时依赖的任务全部在窗口内完成后启动
+	Executor     string            //执行器 空或shell.执行shell命令 http.发送http请求，命令为请求的URL sql.在调度模块中执行sql，见runSql schedule.启动其它调度，见runSchedule
+	SlaStart     string            //最晚开始时间，见slaDeadline
+	SlaFinish    string            //最晚完成时间，见slaDeadline
+	SlaDuration  int64             //最长执行时间，单位秒，0不限制
+	ExecMode     int8              //执行方式 0.普通 1.扇出，标准输出的json数组按属性fanout_template指定的模板任务生成子任务 2.传感器，见sense
 	CreateUserId int64             //创建人
 	CreateTime   *time.Time        //创人
 	ModifyUserId int64             //修改人
@@ -47,24 +63,12 @@ type Task struct { // {{{
 func (t *Task) InitTask(s *Schedule) error { // {{{
 	g.L.Debugf("InitTask[%s] Start ...\n", t.Name)
 	t.GetTask()
+	//依赖的任务可能尚未加载，在调度中全部任务加载后再设置
 	t.getRelTaskId()
-	for _, rtid := range t.RelTasksId {
-		rt := s.GetTaskById(rtid)
-		idkey := strconv.FormatInt(rtid, 10)
-		t.RelTasks[idkey] = rt
-		if rt == nil {
-			e := fmt.Sprintf("[t.InitTask] Task [%d] not found RelTask [%s] .\n", t.Id, idkey)
-			g.L.Warningln(e)
-			continue
-		}
-		t.RelTaskCnt++
-	}
 	s.addTaskList(t)
-	tm, err := Parse(t.Cronstr)
-	if err != nil {
+	if err := t.setTimer(); err != nil {
 		return err
 	}
-	t.timer = tm
 	g.L.Debugf("InitTask[%s] End ...\n", t.Name)
 	return nil
 } // }}}
@@ -90,6 +94,60 @@ func (t *Task) GetTask() error { // {{{
 	return nil
 } // }}}
 
+//resolveRelTasks根据RelTasksId设置依赖的任务，同一调度中的任务放入RelTasks，
+//其它调度中的任务放入ExtTasksId，执行时按周期等待其执行完成。
+//找不到的任务不设置依赖，由checkGraph检查时报错。
+func (t *Task) resolveRelTasks(s *Schedule) { // {{{
+	t.RelTasks = make(map[string]*Task)
+	t.ExtTasksId = make([]int64, 0)
+	t.RelTaskCnt = 0
+	for _, rtid := range t.RelTasksId {
+		if rt := s.GetTaskById(rtid); rt != nil {
+			t.RelTasks[strconv.FormatInt(rtid, 10)] = rt
+			t.RelTaskCnt++
+			continue
+		}
+		if g.Schedules.getTaskById(rtid) == nil {
+			g.L.Warningf("[t.resolveRelTasks] Task [%d] not found RelTask [%d] .\n", t.Id, rtid)
+			continue
+		}
+		t.ExtTasksId = append(t.ExtTasksId, rtid)
+		g.L.Debugf("[t.resolveRelTasks] Task [%d] depends on task [%d] in other schedule.\n", t.Id, rtid)
+	}
+} // }}}
+
+//setTimer根据Task的设置生成计算启动时间的Timer。
+//设置了Cronstr时按crontab格式解析，否则按TaskCyc周期及周期内启动时间StartList或StartSecond计算，
+//两者均未设置时Timer为空，Task不会被定时启动。
+func (t *Task) setTimer() error { // {{{
+	t.timer = nil
+	if t.Cronstr != "" {
+		tm, err := Parse(t.Cronstr)
+		if err != nil {
+			e := fmt.Sprintf("\n[t.setTimer] task [%d] parse cronstr [%s] error %s.", t.Id, t.Cronstr, err.Error())
+			return errors.New(e)
+		}
+		t.timer = tm
+	} else if t.TaskCyc != "" {
+		sm, ss := []int{0}, []time.Duration{t.StartSecond}
+		if t.StartList != "" {
+			var err error
+			if sm, ss, err = ParseStartList(t.StartList); err != nil {
+				e := fmt.Sprintf("\n[t.setTimer] task [%d] %s.", t.Id, err.Error())
+				return errors.New(e)
+			}
+		}
+		tm, err := Cycle(t.TaskCyc, sm, ss)
+		if err != nil {
+			e := fmt.Sprintf("\n[t.setTimer] task [%d] %s.", t.Id, err.Error())
+			return errors.New(e)
+		}
+		t.timer = tm
+	}
+	return nil
+} // }}}
+
+//NextTime计算Task在给定时间之后的下次启动时间，并设置到NextRunTime中。
 func (t *Task) NextTime(now time.Time) time.Time {
 	if t.Disabled != 0 || t.TaskType == 0 {
 		t.NextRunTime = time.Time{}
@@ -97,14 +155,23 @@ func (t *Task) NextTime(now time.Time) time.Time {
 	}
 
 	if t.TaskType == 1 {
-		if t.Cronstr != "" {
+		if t.timer != nil {
 			t.NextRunTime = t.timer.Next(now)
 		} else {
-			t.NextRunTime, _ = getCountDownTime(t.TaskCyc, []int{0}, []time.Duration{t.StartSecond})
+			t.NextRunTime = time.Time{}
+		}
+	} else if t.TaskType == 2 && t.JoinWindow > 0 {
+		//汇合启动的依赖任务在依赖的任务完成后启动，不定时启动
+		t.NextRunTime = time.Time{}
+	} else if t.TaskType == 2 && len(t.RelTasksId) > 0 {
+		//依赖任务跟随第一个依赖的任务启动，依赖其它调度中的任务时按调度周期启动
+		if rt, ok := t.RelTasks[strconv.FormatInt(t.RelTasksId[0], 10)]; ok {
+			t.NextRunTime = rt.NextTime(now)
+		} else if cs, err := Cycle(t.ScheduleCyc, []int{0}, []time.Duration{0}); err == nil {
+			t.NextRunTime = cs.Next(now)
+		} else {
+			t.NextRunTime = time.Time{}
 		}
-	} else if t.TaskType == 2 && len(t.RelTasks) > 0 {
-		idkey := strconv.FormatInt(t.RelTasksId[0], 10)
-		t.NextRunTime = t.RelTasks[idkey].NextTime(now)
 	} else {
 		t.NextRunTime = time.Time{}
 	}
@@ -157,27 +224,11 @@ func (t *Task) Refresh(s *Schedule) error { // {{{
 	}
 
 	t.RelTasksId = make([]int64, 0)
-	t.RelTasks = make(map[string]*Task)
-	t.RelTaskCnt = 0
-
 	err = t.getRelTaskId()
-	for _, rtid := range t.RelTasksId {
-		rt := s.GetTaskById(rtid)
-		idkey := strconv.FormatInt(rtid, 10)
-		t.RelTasks[idkey] = rt
-		if rt == nil {
-			e := fmt.Sprintf("[t.Refresh] Task [%d] not found RelTask [%s] .\n", t.Id, idkey)
-			g.L.Warningln(e)
-			continue
-		}
-		t.RelTaskCnt++
-
-	}
-	tm, err := Parse(t.Cronstr)
-	if err != nil {
+	t.resolveRelTasks(s)
+	if err = t.setTimer(); err != nil {
 		return err
 	}
-	t.timer = tm
 
 	if i == -1 {
 		s.addTaskList(t)
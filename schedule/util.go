@@ -16,54 +16,36 @@ func getCountDown(cyc string, sm []int, ss []time.Duration) (countDown time.Dura
 } // }}}
 
 func getCountDownTime(cyc string, sm []int, ss []time.Duration) (starttime time.Time, err error) { // {{{
-	now := time.Now()
-	var startTime time.Time
-	var b bool //执行时间是否在当前时间之后的标志
-
-	//按周期取整
-	s := TruncDate(cyc, now)
-	for i, st := range ss {
-		if s.AddDate(0, sm[i], 0).Add(st).After(now) {
-			//执行时间在当前时间之后，设置标志，跳出循环进行下一步
-			b = true
-			startTime = s.AddDate(0, sm[i], 0).Add(st)
-			break
-		}
+	cs, err := Cycle(cyc, sm, ss)
+	if err != nil {
+		return time.Now(), err
 	}
 
-	if !b { //在当前时间周期内的执行时间全部小于当前时间，执行应该从下一周期开始
-		//解析周期并取得距下一周期的时间
-		switch {
-		case cyc == "ss":
-			startTime = s.AddDate(0, sm[0], 0).Add(time.Second).Add(ss[0])
-		case cyc == "mi":
-			//按分钟取整
-			startTime = s.AddDate(0, sm[0], 0).Add(time.Minute).Add(ss[0])
-		case cyc == "h":
-			//按小时取整
-			startTime = s.AddDate(0, sm[0], 0).Add(time.Hour).Add(ss[0])
-		case cyc == "d":
-			//按日取整
-			startTime = s.AddDate(0, sm[0], 0).AddDate(0, 0, 1).Add(ss[0])
-		case cyc == "m":
-			//按月取整
-			startTime = s.AddDate(0, sm[0], 0).AddDate(0, 1, 0).Add(ss[0])
-		case cyc == "w":
-			//按周取整
-			startTime = s.AddDate(0, sm[0], 0).AddDate(0, 0, 7).Add(ss[0])
-		case cyc == "q":
-			//回头再处理
-		case cyc == "y":
-			//按年取整
-			startTime = s.AddDate(0, sm[0], 0).AddDate(1, 0, 0).Add(ss[0])
-		}
+	return cs.Next(time.Now()), nil
+} // }}}
 
+//AddCycle返回给定时间加上n个周期后的时间
+func AddCycle(cyc string, t time.Time, n int) time.Time { // {{{
+	switch {
+	case cyc == "ss":
+		return t.Add(time.Duration(n) * time.Second)
+	case cyc == "mi":
+		return t.Add(time.Duration(n) * time.Minute)
+	case cyc == "h":
+		return t.Add(time.Duration(n) * time.Hour)
+	case cyc == "d":
+		return t.AddDate(0, 0, n)
+	case cyc == "w":
+		return t.AddDate(0, 0, 7*n)
+	case cyc == "m":
+		return t.AddDate(0, n, 0)
+	case cyc == "q":
+		return t.AddDate(0, 3*n, 0)
+	case cyc == "y":
+		return t.AddDate(n, 0, 0)
 	}
-	//countDown = startTime.Sub(time.Now())
-
-	return startTime, nil
-
-}
+	return t
+} // }}}
 
 //时间取整
 func TruncDate(cyc string, now time.Time) time.Time { // {{{
@@ -90,7 +72,8 @@ func TruncDate(cyc string, now time.Time) time.Time { // {{{
 		//按周取整
 		return time.Date(now.Year(), now.Month(), now.Day()-int(now.Weekday()), 0, 0, 0, 0, time.Local)
 	case cyc == "q":
-		//回头再处理
+		//按季度取整
+		return time.Date(now.Year(), now.Month()-(now.Month()-1)%3, 1, 0, 0, 0, 0, time.Local)
 	case cyc == "y":
 		//按年取整
 		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.Local)
@@ -99,6 +82,14 @@ func TruncDate(cyc string, now time.Time) time.Time { // {{{
 
 } // }}}
 
+//ParseTime按本地时区解析"2006-01-02"或"2006-01-02 15:04:05"格式的时间
+func ParseTime(s string) (time.Time, error) { // {{{
+	if len(s) == len("2006-01-02") {
+		return time.ParseInLocation("2006-01-02", s, time.Local)
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+} // }}}
+
 //获取当前时间
 func GetNow() time.Time { // {{{
 	return time.Now().Local()
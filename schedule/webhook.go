@@ -0,0 +1,82 @@
+package schedule
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	//webhook的令牌与调度的令牌不一致或调度未设置令牌
+	ErrInvalidToken = errors.New("invalid token")
+	//webhook指定的任务不在调度中
+	ErrTaskNotFound = errors.New("task not found")
+)
+
+//Webhook按外部系统的通知启动调度的执行批次，执行类型为7，启动时间为当前时间。
+//token需与调度的令牌一致，调度未设置令牌时不能触发。
+//taskId不为0时批次中只包含该任务，否则包含调度中全部未禁用的任务。
+//conf为请求中的json参数，任务可以在命令模板中以.conf、在环境变量SCD_CONF中读取。
+//返回构建完成的执行结构，由调用方执行其Run方法。
+//令牌不一致时返回的error包含ErrInvalidToken，任务不存在时包含ErrTaskNotFound。
+func (s *Schedule) Webhook(token string, taskId int64, conf map[string]interface{}) (*ExecSchedule, error) { // {{{
+	if s.Token == "" || subtle.ConstantTimeCompare([]byte(s.Token), []byte(token)) != 1 {
+		return nil, fmt.Errorf("\n[s.Webhook] schedule [%d %s] %w.", s.Id, s.Name, ErrInvalidToken)
+	}
+
+	var ids map[int64]bool
+	if taskId != 0 {
+		t := s.GetTaskById(taskId)
+		if t == nil {
+			return nil, fmt.Errorf("\n[s.Webhook] %w [%d] in schedule [%d %s].", ErrTaskNotFound, taskId, s.Id, s.Name)
+		}
+		ids = map[int64]bool{t.Id: true}
+	}
+
+	es, err := s.trigger(time.Now(), 7, ids, conf, nil)
+	if err != nil {
+		e := fmt.Sprintf("\n[s.Webhook] %s", err.Error())
+		return nil, errors.New(e)
+	}
+
+	return es, nil
+} // }}}
+
+//ResetToken为调度生成新的webhook令牌并持久化，返回新的令牌
+func (s *Schedule) ResetToken() (string, error) { // {{{
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		e := fmt.Sprintf("\n[s.ResetToken] %s", err.Error())
+		return "", errors.New(e)
+	}
+
+	s.Token = hex.EncodeToString(b)
+	if err := s.updateToken(); err != nil {
+		e := fmt.Sprintf("\n[s.ResetToken] %s", err.Error())
+		return "", errors.New(e)
+	}
+
+	return s.Token, nil
+} // }}}
+
+//BatchId返回执行结构的批次ID
+func (es *ExecSchedule) BatchId() string { // {{{
+	return es.batchId
+} // }}}
+
+//confJson返回批次参数的json格式，没有参数时返回空字符串
+func (et *ExecTask) confJson() string { // {{{
+	if et.execJob.conf == nil {
+		return ""
+	}
+	b, err := json.Marshal(et.execJob.conf)
+	if err != nil {
+		g.L.Warnf("[et.confJson] %s\n", err.Error())
+		return ""
+	}
+	return string(b)
+} // }}}
@@ -0,0 +1,38 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+)
+
+//令牌不一致及任务不存在时返回可区分的错误，调用方据此返回不同的状态码
+func TestWebhookErrors(t *testing.T) { // {{{
+	useTestDB(t)
+	s := &Schedule{Id: 1, Name: "s", Token: "secret", Tasks: []*Task{{Id: 1, Name: "t"}}}
+
+	cases := []struct {
+		name   string
+		s      *Schedule
+		token  string
+		taskId int64
+		want   error
+	}{
+		{"wrong token", s, "x", 0, ErrInvalidToken},
+		{"no token", &Schedule{Id: 2, Name: "s2"}, "", 0, ErrInvalidToken},
+		{"unknown task", s, "secret", 9, ErrTaskNotFound},
+		{"quarantined", &Schedule{Id: 3, Name: "s3", Token: "secret", State: 2}, "secret", 0, nil},
+	}
+	for _, c := range cases {
+		_, err := c.s.Webhook(c.token, c.taskId, nil)
+		if err == nil {
+			t.Errorf("%s: Webhook() = nil error, want error", c.name)
+			continue
+		}
+		if c.want != nil && !errors.Is(err, c.want) {
+			t.Errorf("%s: Webhook() = %v, want %v", c.name, err, c.want)
+		}
+		if c.want == nil && (errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrTaskNotFound)) {
+			t.Errorf("%s: Webhook() = %v, want other error", c.name, err)
+		}
+	}
+} // }}}
@@ -39,6 +39,9 @@ func setConfig(config *Config) (*schedule.GlobalConfigStruct, string, string) {
 	dg.L.Level = logrus.Level(loglevel)
 	dg.Port = ":" + port
 	dg.ManagerPort = ":" + managerport
+	dg.AlertUrl = config.AlertUrl
+	dg.MaxRunning = config.MaxRunning
+	dg.MaxRunningPerWorker = config.MaxRunningPerWorker
 
 	return dg, cpuProfName, memProfName
 }
@@ -60,6 +63,11 @@ func main() {
 	config = LoadConfig("config.toml")
 	global, cpuProfName, memProfName := setConfig(config)
 
+	if flag.Arg(0) == "backfill" {
+		backfill(config, global, flag.Args()[1:])
+		return
+	}
+
 	if *isSchedule { // {{{
 		if config.SchedulePidFile != "" {
 			if err := checkAndSetPid(config.SchedulePidFile); err != nil {
@@ -97,18 +105,8 @@ func main() {
 			}()
 		}
 
-		cnn, err := sql.Open(config.Dbinfo["hivedb"].Dbtype, config.Dbinfo["hivedb"].Conn)
-		if err != nil {
-			log.Fatalf("Unable to connect metadata database. %s", err)
-		}
-		global.HiveConn = cnn
+		connectDB(config, global)
 		defer global.HiveConn.Close()
-
-		cnn, err = sql.Open(config.Dbinfo["logdb"].Dbtype, config.Dbinfo["logdb"].Conn)
-		if err != nil {
-			log.Fatalf("Unable to connect metadata database. %s", err)
-		}
-		global.LogConn = cnn
 		defer global.LogConn.Close()
 
 		//初始化
@@ -141,6 +139,93 @@ func main() {
 
 }
 
+//connectDB连接元数据库及日志库
+func connectDB(config *Config, global *schedule.GlobalConfigStruct) { // {{{
+	cnn, err := sql.Open(config.Dbinfo["hivedb"].Dbtype, config.Dbinfo["hivedb"].Conn)
+	if err != nil {
+		log.Fatalf("Unable to connect metadata database. %s", err)
+	}
+	global.HiveConn = cnn
+
+	cnn, err = sql.Open(config.Dbinfo["logdb"].Dbtype, config.Dbinfo["logdb"].Conn)
+	if err != nil {
+		log.Fatalf("Unable to connect metadata database. %s", err)
+	}
+	global.LogConn = cnn
+
+	//sql执行器按名称使用配置中的数据库链接
+	for name, info := range config.Dbinfo {
+		switch name {
+		case "hivedb":
+			global.DataConns[name] = global.HiveConn
+		case "logdb":
+			global.DataConns[name] = global.LogConn
+		default:
+			if cnn, err = sql.Open(info.Dbtype, info.Conn); err != nil {
+				log.Fatalf("Unable to connect database %s. %s", name, err)
+			}
+			global.DataConns[name] = cnn
+		}
+	}
+} // }}}
+
+//backfill回填执行指定调度start至end之间的每个周期，全部周期执行完成后退出。
+//用法：backfill -scd 1 -start 2026-09-01 -end 2026-09-30 [-tasks 1,2] [-parallel 2]
+func backfill(config *Config, global *schedule.GlobalConfigStruct, args []string) { // {{{
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	scdId := fs.Int64("scd", 0, "schedule id")
+	start := fs.String("start", "", "start time, 2006-01-02 or 2006-01-02 15:04:05")
+	end := fs.String("end", "", "end time, 2006-01-02 or 2006-01-02 15:04:05")
+	tasks := fs.String("tasks", "", "task ids separated by comma, empty for all tasks")
+	parallel := fs.Int("parallel", 1, "number of periods run in parallel")
+	fs.Parse(args)
+
+	st, err := schedule.ParseTime(*start)
+	if err != nil {
+		log.Fatalf("Invalid start time '%s'. %s", *start, err)
+	}
+	et, err := schedule.ParseTime(*end)
+	if err != nil {
+		log.Fatalf("Invalid end time '%s'. %s", *end, err)
+	}
+
+	taskIds := []int64{}
+	for _, ts := range strings.Split(*tasks, ",") {
+		if ts = strings.TrimSpace(ts); ts == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid task id '%s'. %s", ts, err)
+		}
+		taskIds = append(taskIds, id)
+	}
+
+	connectDB(config, global)
+	defer global.HiveConn.Close()
+	defer global.LogConn.Close()
+
+	global.Schedules.InitScheduleList()
+	s := global.Schedules.GetScheduleById(*scdId)
+	if s == nil {
+		log.Fatalf("Not found schedule %d", *scdId)
+	}
+	if err = s.InitSchedule(); err != nil {
+		log.Fatalf("Unable to init schedule %d. %s", *scdId, err)
+	}
+
+	periods, err := s.BackfillPeriods(st, et)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Backfill schedule %d, %d periods.", *scdId, len(periods))
+
+	if err = s.Backfill(taskIds, periods, *parallel); err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Backfill schedule %d is done.", *scdId)
+} // }}}
+
 func checkAndSetPid(pidFile string) error { // {{{
 	contents, err := ioutil.ReadFile(pidFile)
 	if err == nil {
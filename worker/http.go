@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//runHttp按http执行器执行任务，任务的命令为请求的URL，其它请求信息从任务属性中获取：
+//  http_method        请求方法，默认GET
+//  http_headers       请求头，json对象格式，如 {"Content-Type":"application/json"}
+//  http_body          请求体
+//  http_expect_status 期望的状态码，逗号分隔，默认2xx均视为成功
+//  http_expect_body   响应体需匹配的正则表达式
+//响应的状态行及响应体作为标准输出返回，状态码或响应体不符合期望时返回错误信息。
+func runHttp(task *Task, reply *Reply) { // {{{
+	method := strings.ToUpper(task.Attr["http_method"])
+	if method == "" {
+		method = "GET"
+	}
+	url := strings.TrimSpace(task.Cmd)
+
+	req, err := http.NewRequest(method, url, strings.NewReader(task.Attr["http_body"]))
+	if err != nil {
+		reply.Err = "error :" + err.Error()
+		l.Warnln(task.Name, "is error url=", url, err)
+		return
+	}
+	if h := task.Attr["http_headers"]; h != "" {
+		headers := make(map[string]string)
+		if err = json.Unmarshal([]byte(h), &headers); err != nil {
+			reply.Err = "error : invalid http_headers " + err.Error()
+			return
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	register(task.BatchTaskId, cancel)
+	defer unregister(task.BatchTaskId)
+
+	client := &http.Client{Timeout: time.Duration(task.TimeOut) * time.Second}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			reply.ErrType = 3
+		}
+		reply.Err = "error :" + err.Error()
+		l.Warnln(task.Name, "is error url=", url, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	reply.ExitCode = resp.StatusCode
+	reply.Stdout = fmt.Sprintf("%s %s\n\n%s", resp.Proto, resp.Status, body)
+	if err != nil {
+		reply.Err = "error : read response " + err.Error()
+		return
+	}
+
+	if !expectStatus(task.Attr["http_expect_status"], resp.StatusCode) {
+		reply.Err = "error : unexpected status " + resp.Status
+		l.Warnln(task.Name, "is error url=", url, "status=", resp.Status)
+		return
+	}
+	if expr := task.Attr["http_expect_body"]; expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			reply.Err = "error : invalid http_expect_body " + err.Error()
+			return
+		}
+		if !re.Match(body) {
+			reply.Err = "error : response body does not match " + expr
+			l.Warnln(task.Name, "is error url=", url, "body does not match", expr)
+			return
+		}
+	}
+
+	l.Infoln(task.Name, "is ok url=", url, "status=", resp.Status)
+} // }}}
+
+//expectStatus判断状态码是否为期望的状态码，未设置期望的状态码时2xx均视为成功
+func expectStatus(expect string, code int) bool { // {{{
+	if strings.TrimSpace(expect) == "" {
+		return code >= 200 && code < 300
+	}
+	for _, s := range strings.Split(expect, ",") {
+		if c, err := strconv.Atoi(strings.TrimSpace(s)); err == nil && c == code {
+			return true
+		}
+	}
+	return false
+} // }}}
@@ -1,16 +1,22 @@
 //worker执行模块worker负责在本地执行调度模块发送的命令，并将输出信息返回给调度模块。
-//worker执行时会启动http服务监听8123端口，提供RPC调用接口CmdExecuter.Run()方法。
+//worker执行时会启动http服务监听8123端口，提供RPC调用接口CmdExecuter.Run()、CmdExecuter.Kill()方法。
 package worker
 
 import (
 	"bytes"
-	"github.com/51idc/go-sh"
+	"errors"
 	"github.com/Sirupsen/logrus"
 	"net"
 	"net/rpc"
+	"os"
+	"os/exec"
+	"regexp"
 	"runtime"
 	"runtime/debug"
-	//"strings"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -18,10 +24,23 @@ var (
 	//全局log对象
 	l = logrus.New()
 	p = l.WithFields
+
+	//执行中的任务结束函数，key为任务批次ID
+	sessions    = make(map[string]func())
+	sessionLock sync.Mutex
+
+	//合法的环境变量名称
+	envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	//命令执行超时
+	errTimeout = errors.New("execute timeout")
 )
 
 const (
 	CMD = "sh"
+
+	//需要导出为环境变量的任务属性名前缀，变量名为去掉前缀后的部分
+	envAttrPrefix = "env_"
 )
 
 func init() { // {{{
@@ -34,6 +53,9 @@ func init() { // {{{
 // 任务信息结构
 type Task struct {
 	Id          int64  // 任务的ID
+	BatchId     string // 批次ID
+	BatchTaskId string // 任务批次ID
+	Executor    string // 执行器 空或shell.执行shell命令 http.发送http请求
 	Address     string // 任务的执行地址
 	Name        string // 任务名称
 	JobType     string // 任务类型
@@ -42,17 +64,23 @@ type Task struct {
 	Cmd         string // 任务执行的命令或脚本、函数名等。
 	TimeOut     int64  // 设定超时时间，0表示不做超时限制。单位秒
 	//Param       []string          // 任务的参数信息
-	Attr       map[string]string // 任务的属性信息
-	JobId      int64             //所属作业ID
-	RelTasks   map[string]*Task  //依赖的任务
-	RelTaskCnt int64             //依赖的任务数量
+	Attr        map[string]string // 任务的属性信息，workdir指定执行目录，shell指定执行命令的shell
+	JobId       int64             //所属作业ID
+	RelTasks    map[string]*Task  //依赖的任务
+	RelTaskCnt  int64             //依赖的任务数量
+	LogicalDate time.Time         //任务处理数据的逻辑日期，即批次所属周期的开始时间
+	Attempt     int               //本次是第几次执行，从1开始
+	Results     map[string]string //依赖的任务发布的结果
+	Conf        string            //批次的参数，json格式
 }
 
 //返回的消息
 type Reply struct {
-	Err    string //错误信息
-	Stdout string //标准输出
-	Stderr string //标准输出
+	Err      string //错误信息
+	Stdout   string //标准输出
+	Stderr   string //标准输出
+	ErrType  int8   //失败类型 0.成功 3.超时 4.命令返回非0 5.被信号结束 6.程序异常 7.其它执行失败
+	ExitCode int    //命令的退出码，http执行器为响应的状态码，未取得时为-1
 }
 
 //RPC结构
@@ -63,8 +91,36 @@ type CmdExecuter struct{}
 //参数task，需要执行的任务信息。
 //参数reply，任务执行输出的信息。
 func (this *CmdExecuter) Run(task *Task, reply *Reply) error { // {{{
-	//执行task任务
-	runCmd(task, reply)
+	//按执行器执行task任务
+	reply.ExitCode = -1
+	switch task.Executor {
+	case "http":
+		runHttp(task, reply)
+	default:
+		runCmd(task, reply)
+	}
+	if reply.Err != "" && reply.ErrType == 0 {
+		reply.ErrType = 7
+	}
+
+	return nil
+} // }}}
+
+//Kill结束指定任务批次ID的任务进程
+//参数batchTaskId，任务批次ID。
+//参数reply，未找到执行中的任务时Err中返回错误信息。
+func (this *CmdExecuter) Kill(batchTaskId string, reply *Reply) error { // {{{
+	sessionLock.Lock()
+	kill, ok := sessions[batchTaskId]
+	sessionLock.Unlock()
+
+	if !ok {
+		reply.Err = "task [" + batchTaskId + "] is not running"
+		return nil
+	}
+
+	kill()
+	l.Infoln("task", batchTaskId, "is killed")
 
 	return nil
 } // }}}
@@ -77,17 +133,38 @@ func runCmd(task *Task, reply *Reply) { // {{{
 			buf.Write(debug.Stack())
 			l.Warnln("panic=", buf.String())
 			reply.Err = "error"
+			reply.ErrType = 6
 			return
 		}
 	}()
 
 	cmdArgs := []string{"-c", task.Cmd}
-	//启动一个goroutine执行任务，超时则直接返回，
-	//正常结束则设置成功执行标志ok
-	//go func() {
-	stdout, stderr, err := sh.Command(CMD, cmdArgs).SetTimeout(time.Duration(task.TimeOut) * 1000 * time.Millisecond).Output()
-	reply.Stdout = string(stdout)
-	reply.Stderr = string(stderr)
+	shell := CMD
+	if task.Attr["shell"] != "" {
+		shell = task.Attr["shell"]
+	}
+	c := exec.Command(shell, cmdArgs...)
+	c.Env = os.Environ()
+	for k, v := range taskEnv(task) {
+		c.Env = append(c.Env, k+"="+v)
+	}
+	c.Dir = task.Attr["workdir"]
+	var stdout, stderr bytes.Buffer
+	c.Stdout, c.Stderr = &stdout, &stderr
+	//命令在单独的进程组中执行，结束任务时连同命令启动的子进程一起结束，
+	//否则子进程会继续执行并占用输出，任务无法结束
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	err := c.Start()
+	if err == nil {
+		kill := func() { syscall.Kill(-c.Process.Pid, syscall.SIGKILL) }
+		register(task.BatchTaskId, kill)
+		defer unregister(task.BatchTaskId)
+		err = wait(c, time.Duration(task.TimeOut)*time.Second, kill)
+	}
+	reply.Stdout = stdout.String()
+	reply.Stderr = stderr.String()
+	reply.ErrType, reply.ExitCode = exitStatus(err)
 	if err != nil {
 		reply.Err = "error :" + err.Error()
 		l.Warnln("error", err)
@@ -96,11 +173,115 @@ func runCmd(task *Task, reply *Reply) { // {{{
 	}
 
 	l.Infoln(task.Name, "is ok TaskCmd=", task.Cmd, "TaskArg=", cmdArgs)
-	//}()
 
 	return
 } // }}}
 
+//wait等待命令结束，timeout大于0时超时后调用kill结束命令并返回errTimeout。
+func wait(c *exec.Cmd, timeout time.Duration, kill func()) error { // {{{
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+
+	if timeout <= 0 {
+		return <-done
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		kill()
+		<-done
+		return errTimeout
+	}
+} // }}}
+
+//exitStatus按命令执行返回的错误判断失败类型及退出码
+func exitStatus(err error) (int8, int) { // {{{
+	if err == nil {
+		return 0, 0
+	}
+	if err == errTimeout {
+		return 3, -1
+	}
+	if ee, ok := err.(*exec.ExitError); ok {
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok {
+			if ws.Signaled() {
+				return 5, -1
+			}
+			return 4, ws.ExitStatus()
+		}
+	}
+	return 7, -1
+} // }}}
+
+//register登记执行中任务的结束函数，供Kill调用
+func register(batchTaskId string, kill func()) { // {{{
+	if batchTaskId == "" {
+		return
+	}
+	sessionLock.Lock()
+	sessions[batchTaskId] = kill
+	sessionLock.Unlock()
+} // }}}
+
+//unregister移除任务的结束函数
+func unregister(batchTaskId string) { // {{{
+	sessionLock.Lock()
+	delete(sessions, batchTaskId)
+	sessionLock.Unlock()
+} // }}}
+
+//taskEnv返回任务执行时设置的环境变量。
+//只导出名称以envAttrPrefix开头的任务属性，如属性env_HIVE_DB导出为HIVE_DB，
+//其它属性如sql_conn、http_headers不导出。变量名不合法或与worker进程已有的
+//环境变量同名时忽略，不覆盖PATH等进程变量。
+//同时导出以SCD_开头的批次信息，与属性同名时以批次信息为准。
+//依赖的任务发布的结果以SCD_RESULT_结果名称导出。
+func taskEnv(task *Task) map[string]string { // {{{
+	env := make(map[string]string)
+	for k, v := range task.Attr {
+		if !strings.HasPrefix(k, envAttrPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(k, envAttrPrefix)
+		if !envName.MatchString(name) {
+			l.Warnln(task.Name, "attr", k, "is not a valid environment variable name, ignored")
+			continue
+		}
+		if _, ok := os.LookupEnv(name); ok {
+			l.Warnln(task.Name, "attr", k, "would override environment variable", name, ", ignored")
+			continue
+		}
+		env[name] = v
+	}
+
+	for k, v := range task.Results {
+		if !envName.MatchString(k) {
+			l.Warnln(task.Name, "result", k, "is not a valid environment variable name, ignored")
+			continue
+		}
+		env["SCD_RESULT_"+k] = v
+	}
+
+	env["SCD_BATCH_ID"] = task.BatchId
+	env["SCD_BATCH_TASK_ID"] = task.BatchTaskId
+	env["SCD_TASK_ID"] = strconv.FormatInt(task.Id, 10)
+	env["SCD_TASK_NAME"] = task.Name
+	env["SCD_JOB_ID"] = strconv.FormatInt(task.JobId, 10)
+	env["SCD_ATTEMPT"] = strconv.Itoa(task.Attempt)
+	if task.Conf != "" {
+		env["SCD_CONF"] = task.Conf
+	}
+	if !task.LogicalDate.IsZero() {
+		env["SCD_LOGICAL_DATE"] = task.LogicalDate.Format("2006-01-02 15:04:05")
+		env["SCD_DS"] = task.LogicalDate.Format("2006-01-02")
+	}
+
+	return env
+} // }}}
+
 //启动HTTP服务监控指定端口
 func ListenAndServer(port string) { // {{{
 	executer := new(CmdExecuter)
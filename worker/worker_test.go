@@ -1,243 +1,105 @@
 package worker
 
 import (
-	"bytes"
 	"errors"
-	"github.com/Sirupsen/logrus"
-	"io"
-	"net/http"
-	"net/rpc"
+	"os"
 	"os/exec"
-	_ "runtime"
-	"runtime/debug"
+	"path/filepath"
 	"testing"
 	"time"
 )
 
-func TestAllSpecs(t *testing.T) {
-	r := gospec.NewRunner()
-	r.Parallel = false
+//超时后命令及其启动的子进程都应被结束，不能等到子进程退出才返回
+func TestRunCmdTimeoutKillsGroup(t *testing.T) { // {{{
+	mark := filepath.Join(t.TempDir(), "mark")
+	task := &Task{Name: "timeout", Cmd: "(sleep 2; touch " + mark + ") & sleep 30", TimeOut: 1}
+	reply := &Reply{}
 
-	r.AddSpec(InputRunnerSpec)
-	r.AddSpec(OutputRunnerSpec)
-	r.AddSpec(ProtobufDecoderSpec)
-	r.AddSpec(ReportSpec)
-	r.AddSpec(StatAccumInputSpec)
-	r.AddSpec(StreamParserSpec)
-
-	gospec.MainGoTest(r, t)
-}
-
-func BenchmarkPipelinePackCreation(b *testing.B) {
-	var config = PipelineConfig{}
-	for i := 0; i < b.N; i++ {
-		NewPipelinePack(config.inputRecycleChan)
+	start := time.Now()
+	runCmd(task, reply)
+	if d := time.Since(start); d > 10*time.Second {
+		t.Fatalf("runCmd returned after %s, children were not killed", d)
+	}
+	if reply.ErrType != 3 {
+		t.Errorf("ErrType = %d, want 3", reply.ErrType)
 	}
-}
-
-var (
-	// 监听端口号
-	gPort string = ":8123"
-	//全局log对象
-	log = logrus.New()
-)
-
-func init() {
-	//设置log模块的默认格式
-	log.Formatter = new(logrus.TextFormatter) // default
-}
-
-// 任务信息结构
-type Job struct {
-	Id      int64             // job的id
-	Name    string            // 名称
-	Type    string            // 类型
-	Cmd     string            // job执行的命令或脚本、函数名等。
-	TimeOut int64             // 设定超时时间，0表示不做超时限制。单位秒
-	Param   map[string]string // Job的参数信息
-}
-
-//返回的消息
-type Reply struct {
-	Stdout string //命令的标准输出
-}
 
-//RPC结构
-//服务端处理部分，接受client端发送的指令。
-type Executer struct {
-}
+	time.Sleep(2 * time.Second)
+	if _, err := os.Stat(mark); err == nil {
+		t.Errorf("child process kept running after timeout")
+	}
+} // }}}
 
-//Run调用相应的模块，完成对Job的执行
-//参数job，需要执行的任务信息。
-//参数reply，任务执行输出的信息。
-func (this *Executer) Run(job *Job, reply *Reply) error {
-	defer func() {
-		if err := recover(); err != nil {
-			var buf bytes.Buffer
-			buf.Write(debug.Stack())
-			log.WithFields(logrus.Fields{
-				"panic": buf.String(),
-			}).Warn("worker.Executer.Run()")
-		}
-	}()
+//Kill结束任务时同样结束整个进程组
+func TestKillGroup(t *testing.T) { // {{{
+	task := &Task{Name: "kill", BatchTaskId: "test-kill", Cmd: "sleep 30 & sleep 30"}
+	reply := &Reply{}
 
-	//channel类型变量chRp用来实时传递命令执行过程中的输出信息。
-	chRp := make(chan string, 0)
 	go func() {
-		for {
-			select {
-			case msg := <-chRp:
-				//设置返回值
-				reply.Stdout += msg
-				log.WithFields(logrus.Fields{
-					"cmdlog": msg,
-				}).Info("worker.Executer.Run")
-			}
-		}
-	}()
-
-	//执行job任务
-	err := runCmd(job, chRp)
-
-	return err
-}
-
-//runCmd用来执行参数cmd中指定的命令，并返回执行时间和错误信息。
-func runCmd(job *Job, reply chan string) error {
-	defer func() {
-		if err := recover(); err != nil {
-			var buf bytes.Buffer
-			buf.Write(debug.Stack())
-			log.WithFields(logrus.Fields{
-				"panic": buf.String(),
-			}).Warn("worker.runCmd")
-		}
+		time.Sleep(500 * time.Millisecond)
+		(&CmdExecuter{}).Kill(task.BatchTaskId, &Reply{})
 	}()
 
-	var c *exec.Cmd
-	var cmdArgs []string //执行的命令行参数
-
-	//从job结构中获取并组合命令参数
-	for _, v := range job.Param {
-		cmdArgs = append(cmdArgs, v)
+	start := time.Now()
+	runCmd(task, reply)
+	if d := time.Since(start); d > 10*time.Second {
+		t.Fatalf("runCmd returned after %s, children were not killed", d)
 	}
+	if reply.ErrType != 5 {
+		t.Errorf("ErrType = %d, want 5", reply.ErrType)
+	}
+} // }}}
 
-	//记录开始执行的时间
-	startTime := time.Now().Format("2006-01-02 15:04:05")
-
-	//命令成功执行标志
-	ok := make(chan bool, 1)
-	chErr := make(chan error, 1)
-
-	//启动一个goroutine执行任务，超时则直接返回，
-	//正常结束则设置成功执行标志ok
-	go func() {
-		c = exec.Command(job.Cmd, cmdArgs...)
-
-		stdout, err := c.StdoutPipe() //挂载标准输出
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"StdoutPipe": err.Error(),
-			}).Warn("worker.runCmd")
-			chErr <- err
-			return
-		}
-
-		stderr, err := c.StderrPipe() //挂着错误输出
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"StderrPipe": err.Error(),
-			}).Warn("worker.runCmd")
-			chErr <- err
-			return
-		}
-
-		r := io.MultiReader(stdout, stderr)
-		if err := c.Start(); err != nil {
-			log.WithFields(logrus.Fields{
-				"Start": err.Error(),
-			}).Warn("worker.runCmd")
-			chErr <- err
-			return
-		}
-
-		//读取输出信息，设置到reply通道中
-		for {
-			bf := make([]byte, 1024)
-			count, err := r.Read(bf)
-			if err != nil || count == 0 {
-				break
-			} else {
-				reply <- string(bf)
-			}
+func TestExitStatus(t *testing.T) { // {{{
+	run := func(cmd string) error {
+		return exec.Command("/bin/sh", "-c", cmd).Run()
+	}
+	cases := []struct {
+		name    string
+		err     error
+		errType int8
+		code    int
+	}{
+		{"success", nil, 0, 0},
+		{"timeout", errTimeout, 3, -1},
+		{"exit 1", run("exit 1"), 4, 1},
+		{"exit 3", run("exit 3"), 4, 3},
+		{"signal", run("kill -9 $$"), 5, -1},
+		{"not found", exec.Command("/nonexistent/cmd").Run(), 7, -1},
+		{"other", errors.New("other"), 7, -1},
+	}
+	for _, c := range cases {
+		errType, code := exitStatus(c.err)
+		if errType != c.errType || code != c.code {
+			t.Errorf("%s: exitStatus(%v) = %d, %d, want %d, %d", c.name, c.err, errType, code, c.errType, c.code)
 		}
-
-		if err := c.Wait(); err != nil {
-			log.WithFields(logrus.Fields{
-				"Wait": err.Error(),
-			}).Warn("worker.runCmd")
-			chErr <- err
-			return
+	}
+} // }}}
+
+//只导出env_前缀的属性，不覆盖进程已有的环境变量，批次信息优先于属性
+func TestTaskEnv(t *testing.T) { // {{{
+	t.Setenv("SCD_TEST_EXISTING", "keep")
+	task := &Task{Id: 3, Name: "t", BatchId: "b", Attr: map[string]string{
+		"env_HIVE_DB":           "dw",
+		"env_SCD_TEST_EXISTING": "override",
+		"env_PATH":              "/tmp",
+		"env_SCD_BATCH_ID":      "x",
+		"env_1bad":              "bad",
+		"sql_conn":              "user:pass@tcp(db)/dw",
+		"http_headers":          `{"Authorization": "secret"}`,
+		"workdir":               "/tmp",
+	}}
+	env := taskEnv(task)
+
+	want := map[string]string{"HIVE_DB": "dw", "SCD_BATCH_ID": "b", "SCD_TASK_ID": "3"}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("env[%s] = %q, want %q", k, env[k], v)
 		}
-
-		ok <- true
-	}()
-
-	//监听通道，超时则kill掉进程
-	select {
-	case <-time.After(time.Duration(job.TimeOut) * time.Millisecond):
-		log.WithFields(logrus.Fields{
-			"StartTime": startTime,
-			"EndTime":   time.Now().Format("2006-01-02 15:04:05"),
-			"JobId":     job.Id,
-			"JobName":   job.Name,
-			"JobCmd":    job.Cmd,
-			"JobArg":    cmdArgs,
-		}).Warn("worker.runCmd is timeout")
-		c.Process.Kill()
-		return errors.New("time out")
-	case e := <-chErr:
-		//异常退出
-		log.WithFields(logrus.Fields{
-			"StartTime": startTime,
-			"EndTime":   time.Now().Format("2006-01-02 15:04:05"),
-			"JobId":     job.Id,
-			"JobName":   job.Name,
-			"JobCmd":    job.Cmd,
-			"JobArg":    cmdArgs,
-		}).Warn("worker.runCmd is error")
-		return e
-	case <-ok:
-		//正常退出
-		log.WithFields(logrus.Fields{
-			"StartTime": startTime,
-			"EndTime":   time.Now().Format("2006-01-02 15:04:05"),
-			"JobId":     job.Id,
-			"JobName":   job.Name,
-			"JobCmd":    job.Cmd,
-			"JobArg":    cmdArgs,
-		}).Info("worker.runCmd is ok")
-		return nil
 	}
-
-	return nil
-}
-
-func main() {
-	executer := new(Executer)
-	rpc.Register(executer)
-	rpc.HandleHTTP()
-
-	log.WithFields(logrus.Fields{
-		"Port": gPort,
-	}).Info("Server is running")
-
-	err := http.ListenAndServe(gPort, nil)
-
-	if err != nil {
-		log.WithFields(logrus.Fields{
-			"error": err.Error(),
-		}).Warn("error!")
+	for _, k := range []string{"SCD_TEST_EXISTING", "PATH", "1bad", "sql_conn", "http_headers", "workdir", "env_HIVE_DB"} {
+		if v, ok := env[k]; ok {
+			t.Errorf("env[%s] = %q exported, want not exported", k, v)
+		}
 	}
-
-}
+} // }}}